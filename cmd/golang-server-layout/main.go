@@ -2,12 +2,15 @@ package main
 
 import (
 	"config"
+	"db"
+	"db/migrations"
 	"encoding/json"
 	"handlers"
 	"log"
+	"mailer"
+	"middlewares"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -17,6 +20,47 @@ func main() {
 	config.Initialize()
 	log.Println("Configuration loaded.")
 
+	// Open the connection pool shared by every handler
+	store, err := db.Open()
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer store.Close()
+
+	// `app migrate up|down|status` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(store, os.Args[2:])
+		return
+	}
+
+	// Bring the schema up to date before anything else touches it
+	count, err := migrations.Up(store.DB)
+	if err != nil {
+		log.Fatalf("Error applying migrations: %v", err)
+	}
+	log.Printf("Database ready (%d migration(s) applied).", count)
+
+	// `app role NICKNAME ROLE` promotes a user, e.g. the first admin
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		runRole(store, os.Args[2:])
+		return
+	}
+
+	// `app purge` removes what was deleted more than DELETED_RETENTION ago
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		runPurge(store)
+		return
+	}
+
+	// Emails are written to MAIL_DIR (or the log) until a real mailer exists
+	mail, err := mailer.NewLogMailer(config.MAIL_DIR)
+	if err != nil {
+		log.Fatalf("Error setting up mailer: %v", err)
+	}
+
+	middlewares.Init(store)
+	handlers.Init(store, mail)
+
 	// Configure router and server
 	mux := setupMux()
 	server := setupServer(mux)
@@ -52,6 +96,8 @@ func setupMux() *http.ServeMux {
 	mux.HandleFunc("/register", handlers.RegisterHandler)
 	mux.HandleFunc("/login", handlers.LoginHandler)
 	mux.HandleFunc("/api/check-session", handlers.CheckSession)
+	mux.HandleFunc("POST /api/password-reset", handlers.HandlePasswordResetRequest)
+	mux.HandleFunc("POST /api/password-reset/confirm", handlers.HandlePasswordResetConfirm)
 
 	// Replace the WebSocket route with a conditional
 	if os.Getenv("PORT") != "" {
@@ -66,39 +112,55 @@ func setupMux() *http.ServeMux {
 		})
 	} else {
 		// Local development - use real WebSockets
-		mux.HandleFunc("/ws", handlers.HandleConnection)
+		mux.HandleFunc("/ws", middlewares.RequireAuth(handlers.HandleConnection))
 	}
 
 	// API routes
 	mux.HandleFunc("/api/users", handlers.GetConnectedAndDisconnectedUsers)
 	mux.HandleFunc("/api/user", handlers.GetUserByIdHandler)
-	mux.HandleFunc("/api/post", handlers.CreatePostHandler)
 	mux.HandleFunc("/api/posts", handlers.HandleFetchPosts)
-	mux.HandleFunc("/api/postCreation", handlers.HandleCreatePost)
-	mux.HandleFunc("/api/posts/new", handlers.HandleFetchNewPosts)
-	mux.HandleFunc("/api/navbar", handlers.NavbarHandler)
-
-	// Handle comment-related routes
-	mux.HandleFunc("/api/posts/", func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-
-		// Check if this matches the comments pattern: /api/posts/{postId}/comments
-		if strings.Contains(path, "/comments") {
-			if r.Method == http.MethodGet {
-				handlers.FetchPostCommentsHandler(w, r)
-				return
-			} else if r.Method == http.MethodPost {
-				handlers.CreateCommentHandler(w, r)
-				return
-			}
-		}
-
-		// If we get here, it wasn't a comments request
-		http.NotFound(w, r)
-	})
+	mux.HandleFunc("/api/postCreation", middlewares.RequireVerified(handlers.HandleCreatePost))
+	mux.HandleFunc("GET /api/categories", handlers.HandleFetchCategories)
+	mux.HandleFunc("/api/navbar", middlewares.OptionalAuth(handlers.NavbarHandler))
+	mux.HandleFunc("GET /api/conversations", middlewares.RequireAuth(handlers.HandleConversations))
+	mux.HandleFunc("GET /api/conversations/{user}/messages", middlewares.RequireAuth(handlers.HandleConversationMessages))
+
+	// Editing and deleting, for the author or a moderator
+	mux.HandleFunc("PUT /api/posts/{id}", middlewares.RequireAuth(handlers.HandleUpdatePost))
+	mux.HandleFunc("PATCH /api/posts/{id}", middlewares.RequireAuth(handlers.HandleUpdatePost))
+	mux.HandleFunc("DELETE /api/posts/{id}", middlewares.RequireAuth(handlers.HandleDeletePost))
+	mux.HandleFunc("GET /api/posts/{id}/revisions", handlers.HandlePostRevisions)
+	mux.HandleFunc("PUT /api/posts/{id}/comments/{cid}", middlewares.RequireAuth(handlers.HandleUpdateComment))
+	mux.HandleFunc("DELETE /api/posts/{id}/comments/{cid}", middlewares.RequireAuth(handlers.HandleDeleteComment))
+	mux.HandleFunc("GET /api/posts/{id}/comments/{cid}/revisions", handlers.HandleCommentRevisions)
+	mux.HandleFunc("PATCH /api/comments/{id}", middlewares.RequireAuth(handlers.HandleUpdateComment))
+	mux.HandleFunc("DELETE /api/comments/{id}", middlewares.RequireAuth(handlers.HandleDeleteComment))
+	mux.HandleFunc("POST /api/posts/{id}/restore", middlewares.RequireAuth(handlers.HandleRestorePost))
+	mux.HandleFunc("POST /api/posts/{id}/comments/{cid}/restore", middlewares.RequireAuth(handlers.HandleRestoreComment))
+	mux.HandleFunc("DELETE /api/messages/{id}", middlewares.RequireAuth(handlers.HandleDeleteMessage))
+	mux.HandleFunc("POST /api/messages/{id}/restore", middlewares.RequireAuth(handlers.HandleRestoreMessage))
+
+	// Administration
+	mux.HandleFunc("PATCH /api/users/{id}/role", middlewares.RequireAuth(handlers.HandleUpdateUserRole))
+	mux.HandleFunc("DELETE /api/users/{id}", middlewares.RequireAuth(handlers.HandleDeleteUser))
+	mux.HandleFunc("POST /api/users/{id}/restore", middlewares.RequireAuth(handlers.HandleRestoreUser))
+
+	// Comments of a post; nothing else lives under /api/posts/
+	mux.HandleFunc("GET /api/posts/{id}/comments", handlers.FetchPostCommentsHandler)
+	mux.HandleFunc("POST /api/posts/{id}/comments", middlewares.RequireVerified(handlers.CreateCommentHandler))
+	mux.HandleFunc("/api/posts/", http.NotFound)
 
 	// Session management
-	mux.HandleFunc("/logout", handlers.LogOutHandler)
+	mux.HandleFunc("/logout", middlewares.OptionalAuth(handlers.LogOutHandler))
+	mux.HandleFunc("GET /api/sessions", middlewares.RequireAuth(handlers.HandleListSessions))
+	mux.HandleFunc("DELETE /api/sessions", middlewares.RequireAuth(handlers.HandleRevokeAllSessions))
+	mux.HandleFunc("DELETE /api/sessions/{id}", middlewares.RequireAuth(handlers.HandleRevokeSession))
+
+	// Account of the logged-in user
+	mux.HandleFunc("PATCH /api/me", middlewares.RequireAuth(handlers.HandleUpdateProfile))
+	mux.HandleFunc("POST /api/me/password", middlewares.RequireAuth(handlers.HandleChangePassword))
+	mux.HandleFunc("POST /api/verify-email", handlers.HandleVerifyEmail)
+	mux.HandleFunc("POST /api/verify-email/resend", middlewares.RequireAuth(handlers.HandleResendVerification))
 
 	return mux
 }
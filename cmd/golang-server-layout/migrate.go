@@ -0,0 +1,56 @@
+package main
+
+import (
+	"db"
+	"db/migrations"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// runMigrate handles `migrate up|down [steps]|status` from the command line
+func runMigrate(store *db.Store, args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrations.Up(store.DB)
+		if err != nil {
+			log.Fatalf("Error applying migrations: %v", err)
+		}
+		log.Printf("Applied %d migration(s).", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		count, err := migrations.Down(store.DB, steps)
+		if err != nil {
+			log.Fatalf("Error reverting migrations: %v", err)
+		}
+		log.Printf("Reverted %d migration(s).", count)
+
+	case "status":
+		states, err := migrations.Status(store.DB)
+		if err != nil {
+			log.Fatalf("Error reading migration status: %v", err)
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		log.Fatalf("Unknown migrate command %q (expected up, down or status)", args[0])
+	}
+}
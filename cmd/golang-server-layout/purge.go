@@ -0,0 +1,19 @@
+package main
+
+import (
+	"config"
+	"db"
+	"log"
+	"time"
+)
+
+// runPurge removes for good what was deleted more than
+// config.DELETED_RETENTION ago, as the server does every
+// RETENTION_PURGE_INTERVAL
+func runPurge(store *db.Store) {
+	purged, err := store.PurgeDeleted(time.Now().Add(-config.DELETED_RETENTION))
+	if err != nil {
+		log.Fatalf("Error purging deleted content: %v", err)
+	}
+	log.Printf("Purged %d deleted row(s).", purged)
+}
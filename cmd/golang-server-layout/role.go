@@ -0,0 +1,35 @@
+package main
+
+import (
+	"db"
+	"log"
+	"middlewares"
+)
+
+// runRole handles `role NICKNAME ROLE` from the command line, the way to
+// appoint the first administrator
+func runRole(store *db.Store, args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: role NICKNAME User|Moderator|Admin")
+	}
+	nickname, role := args[0], args[1]
+	if !middlewares.ValidRole(role) {
+		log.Fatalf("Unknown role %q (expected User, Moderator or Admin)", role)
+	}
+
+	userID := store.UserIDWithNickname(nickname)
+	if userID == 0 {
+		log.Fatalf("No user named %q", nickname)
+	}
+	user, err := store.UserSelectByID(userID)
+	if err != nil {
+		log.Fatalf("Error loading user: %v", err)
+	}
+
+	err = store.UserUpdate(user.ID, user.NickName, user.Gender, user.FirstName,
+		user.LastName, user.Email, role)
+	if err != nil {
+		log.Fatalf("Error updating role: %v", err)
+	}
+	log.Printf("%s is now %s.", nickname, role)
+}
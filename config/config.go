@@ -1,15 +1,92 @@
 package config
 
 import (
+	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
 	DB_PATH string
 	DB_USER = "admin"
 	DB_PW   = "password"
+
+	// Connection pool settings shared by every repository function
+	DB_MAX_OPEN_CONNS = 10
+	DB_BUSY_TIMEOUT   = 5000 // milliseconds a connection waits for the write lock
+
+	// WebSocket heartbeat: the server pings every WS_PING_PERIOD and drops a
+	// connection that hasn't answered within WS_PONG_WAIT
+	WS_WRITE_WAIT  = 10 * time.Second
+	WS_PONG_WAIT   = 60 * time.Second
+	WS_PING_PERIOD = 54 * time.Second // must stay below WS_PONG_WAIT
+	WS_READ_LIMIT  = 16384            // largest frame accepted from a client, in bytes
+
+	// A login lasts SESSION_LIFETIME at most, and ends sooner when it goes
+	// unused for SESSION_IDLE_TIMEOUT. Expired sessions are purged every
+	// SESSION_CLEANUP_INTERVAL.
+	SESSION_LIFETIME         = 7 * 24 * time.Hour
+	SESSION_IDLE_TIMEOUT     = 24 * time.Hour
+	SESSION_CLEANUP_INTERVAL = 10 * time.Minute
+
+	// Login throttling. After LOGIN_FREE_ATTEMPTS failures in a row an account
+	// has to wait LOGIN_BACKOFF before trying again, doubling with every new
+	// failure, and is locked for LOGIN_LOCKOUT once it reaches
+	// LOGIN_MAX_FAILURES. The same goes for an address with the LOGIN_IP_*
+	// limits. Only failures within LOGIN_ATTEMPT_WINDOW count.
+	LOGIN_FREE_ATTEMPTS    = 3
+	LOGIN_MAX_FAILURES     = 5
+	LOGIN_IP_FREE_ATTEMPTS = 10
+	LOGIN_IP_MAX_FAILURES  = 30
+	LOGIN_BACKOFF          = time.Second
+	LOGIN_LOCKOUT          = 15 * time.Minute
+	LOGIN_ATTEMPT_WINDOW   = time.Hour
+
+	// Address of the site, used to build the links sent by email
+	APP_URL = "http://localhost:8080"
+	// Directory where the development mailer writes emails, the log if empty
+	MAIL_DIR = ""
+	// How long a password reset link can be used
+	PASSWORD_RESET_LIFETIME = time.Hour
+	// A login can ask for PASSWORD_RESET_MAX_REQUESTS reset links and an
+	// address for PASSWORD_RESET_IP_MAX_REQUESTS within PASSWORD_RESET_WINDOW
+	PASSWORD_RESET_MAX_REQUESTS    = 3
+	PASSWORD_RESET_IP_MAX_REQUESTS = 10
+	PASSWORD_RESET_WINDOW          = time.Hour
+
+	// Password policy: at least PASSWORD_MIN_LENGTH characters mixing
+	// PASSWORD_MIN_CLASSES kinds among lowercase, uppercase, digits and symbols
+	PASSWORD_MIN_LENGTH  = 8
+	PASSWORD_MIN_CLASSES = 2
+
+	// Nicknames are NICKNAME_MIN_LENGTH to NICKNAME_MAX_LENGTH characters,
+	// all matching NICKNAME_CHARSET (a regexp character class)
+	NICKNAME_MIN_LENGTH = 3
+	NICKNAME_MAX_LENGTH = 20
+	NICKNAME_CHARSET    = `[A-Za-z0-9_.-]`
+
+	// New accounts are sent a link to verify their email address, valid for
+	// EMAIL_VERIFICATION_LIFETIME. With REQUIRE_VERIFIED_EMAIL they can't
+	// post, comment or send messages until they open it.
+	EMAIL_VERIFICATION_LIFETIME = 48 * time.Hour
+	REQUIRE_VERIFIED_EMAIL      = false
+	// An account can have EMAIL_VERIFICATION_MAX_REQUESTS verification
+	// emails sent, and an address receive EMAIL_VERIFICATION_ADDRESS_MAX_REQUESTS,
+	// within EMAIL_VERIFICATION_WINDOW
+	EMAIL_VERIFICATION_MAX_REQUESTS         = 3
+	EMAIL_VERIFICATION_ADDRESS_MAX_REQUESTS = 3
+	EMAIL_VERIFICATION_WINDOW               = time.Hour
+
+	// Deleted posts, comments, messages and users can be restored for
+	// DELETED_RETENTION, then they are purged for good. The purge runs every
+	// RETENTION_PURGE_INTERVAL.
+	DELETED_RETENTION        = 30 * 24 * time.Hour
+	RETENTION_PURGE_INTERVAL = time.Hour
 )
 
 // Initialize function to validate and create necessary paths
@@ -20,6 +97,59 @@ func Initialize() {
 
 	// Set DB_PATH to the absolute path
 	DB_PATH = filepath.Join(projectRoot, "internal", "db", "forum.db")
+	if path := os.Getenv("DB_PATH"); path != "" {
+		DB_PATH = path
+	}
+
+	// Let the WebSocket heartbeat be tuned per deployment
+	WS_WRITE_WAIT = envDuration("WS_WRITE_WAIT", WS_WRITE_WAIT)
+	WS_PONG_WAIT = envDuration("WS_PONG_WAIT", WS_PONG_WAIT)
+	WS_PING_PERIOD = envDuration("WS_PING_PERIOD", WS_PING_PERIOD)
+	WS_READ_LIMIT = envInt("WS_READ_LIMIT", WS_READ_LIMIT)
+	SESSION_LIFETIME = envDuration("SESSION_LIFETIME", SESSION_LIFETIME)
+	SESSION_IDLE_TIMEOUT = envDuration("SESSION_IDLE_TIMEOUT", SESSION_IDLE_TIMEOUT)
+	SESSION_CLEANUP_INTERVAL = envDuration("SESSION_CLEANUP_INTERVAL", SESSION_CLEANUP_INTERVAL)
+	LOGIN_FREE_ATTEMPTS = envInt("LOGIN_FREE_ATTEMPTS", LOGIN_FREE_ATTEMPTS)
+	LOGIN_MAX_FAILURES = envInt("LOGIN_MAX_FAILURES", LOGIN_MAX_FAILURES)
+	LOGIN_IP_FREE_ATTEMPTS = envInt("LOGIN_IP_FREE_ATTEMPTS", LOGIN_IP_FREE_ATTEMPTS)
+	LOGIN_IP_MAX_FAILURES = envInt("LOGIN_IP_MAX_FAILURES", LOGIN_IP_MAX_FAILURES)
+	LOGIN_BACKOFF = envDuration("LOGIN_BACKOFF", LOGIN_BACKOFF)
+	LOGIN_LOCKOUT = envDuration("LOGIN_LOCKOUT", LOGIN_LOCKOUT)
+	LOGIN_ATTEMPT_WINDOW = envDuration("LOGIN_ATTEMPT_WINDOW", LOGIN_ATTEMPT_WINDOW)
+	if url := os.Getenv("APP_URL"); url != "" {
+		APP_URL = strings.TrimRight(url, "/")
+	}
+	MAIL_DIR = os.Getenv("MAIL_DIR")
+	PASSWORD_RESET_LIFETIME = envDuration("PASSWORD_RESET_LIFETIME", PASSWORD_RESET_LIFETIME)
+	PASSWORD_RESET_MAX_REQUESTS = envInt("PASSWORD_RESET_MAX_REQUESTS", PASSWORD_RESET_MAX_REQUESTS)
+	PASSWORD_RESET_IP_MAX_REQUESTS = envInt("PASSWORD_RESET_IP_MAX_REQUESTS", PASSWORD_RESET_IP_MAX_REQUESTS)
+	PASSWORD_RESET_WINDOW = envDuration("PASSWORD_RESET_WINDOW", PASSWORD_RESET_WINDOW)
+	PASSWORD_MIN_LENGTH = envInt("PASSWORD_MIN_LENGTH", PASSWORD_MIN_LENGTH)
+	PASSWORD_MIN_CLASSES = envInt("PASSWORD_MIN_CLASSES", PASSWORD_MIN_CLASSES)
+	if PASSWORD_MIN_CLASSES > 4 {
+		PASSWORD_MIN_CLASSES = 4
+		log.Printf("PASSWORD_MIN_CLASSES can't be above 4, using 4")
+	}
+	NICKNAME_MIN_LENGTH = envInt("NICKNAME_MIN_LENGTH", NICKNAME_MIN_LENGTH)
+	NICKNAME_MAX_LENGTH = envInt("NICKNAME_MAX_LENGTH", NICKNAME_MAX_LENGTH)
+	EMAIL_VERIFICATION_LIFETIME = envDuration("EMAIL_VERIFICATION_LIFETIME", EMAIL_VERIFICATION_LIFETIME)
+	EMAIL_VERIFICATION_MAX_REQUESTS = envInt("EMAIL_VERIFICATION_MAX_REQUESTS", EMAIL_VERIFICATION_MAX_REQUESTS)
+	EMAIL_VERIFICATION_ADDRESS_MAX_REQUESTS = envInt("EMAIL_VERIFICATION_ADDRESS_MAX_REQUESTS", EMAIL_VERIFICATION_ADDRESS_MAX_REQUESTS)
+	EMAIL_VERIFICATION_WINDOW = envDuration("EMAIL_VERIFICATION_WINDOW", EMAIL_VERIFICATION_WINDOW)
+	REQUIRE_VERIFIED_EMAIL = envBool("REQUIRE_VERIFIED_EMAIL", REQUIRE_VERIFIED_EMAIL)
+	DELETED_RETENTION = envDuration("DELETED_RETENTION", DELETED_RETENTION)
+	RETENTION_PURGE_INTERVAL = envDuration("RETENTION_PURGE_INTERVAL", RETENTION_PURGE_INTERVAL)
+	if charset := os.Getenv("NICKNAME_CHARSET"); charset != "" {
+		if _, err := regexp.Compile(charset); err != nil {
+			log.Printf("Ignoring invalid NICKNAME_CHARSET=%q", charset)
+		} else {
+			NICKNAME_CHARSET = charset
+		}
+	}
+	if WS_PING_PERIOD >= WS_PONG_WAIT {
+		WS_PING_PERIOD = WS_PONG_WAIT * 9 / 10
+		log.Printf("WS_PING_PERIOD must be shorter than WS_PONG_WAIT, using %v", WS_PING_PERIOD)
+	}
 
 	// Ensure the database directory exists
 	dbDir := filepath.Dir(DB_PATH)
@@ -27,3 +157,45 @@ func Initialize() {
 		os.MkdirAll(dbDir, 0755)
 	}
 }
+
+// envDuration reads a duration such as "30s" from the environment
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Ignoring invalid %s=%q", name, value)
+		return fallback
+	}
+	return d
+}
+
+// envInt reads a positive integer from the environment
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Ignoring invalid %s=%q", name, value)
+		return fallback
+	}
+	return n
+}
+
+// envBool reads a boolean such as "true" or "0" from the environment
+func envBool(name string, fallback bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q", name, value)
+		return fallback
+	}
+	return b
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"models"
+	"strings"
+)
+
+// Read - List the categories by name, with the number of posts in each
+func (s *Store) CategorySelectAll() ([]models.Category, error) {
+	query := `SELECT c.id, c.name, COUNT(p.id)
+              FROM category c
+              LEFT JOIN post_category pc ON pc.category_id = c.id
+              LEFT JOIN post p ON p.id = pc.post_id AND p.deleted_at IS NULL
+              GROUP BY c.id
+              ORDER BY c.name`
+
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying categories: %v", err)
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.PostCount); err != nil {
+			return nil, fmt.Errorf("error scanning category: %v", err)
+		}
+		categories = append(categories, category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return categories, nil
+}
+
+// Read - Get the ID of a category from its name, whatever its case. 0 if
+// there is none.
+func (s *Store) CategoryIDWithName(name string) (int, error) {
+	var id int
+	err := s.DB.QueryRow(`SELECT id FROM category WHERE name = ?`, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %v", err)
+	}
+	return id, nil
+}
+
+// postCategoriesColumn selects the categories of the post aliased p, joined
+// by categorySeparator; read it with splitCategories
+const postCategoriesColumn = `(SELECT GROUP_CONCAT(c.name, char(31))
+              FROM post_category pc JOIN category c ON pc.category_id = c.id
+              WHERE pc.post_id = p.id)`
+
+const categorySeparator = "\x1f"
+
+// splitCategories reads the value selected with postCategoriesColumn
+func splitCategories(value sql.NullString) []string {
+	if !value.Valid || value.String == "" {
+		return []string{}
+	}
+	return strings.Split(value.String, categorySeparator)
+}
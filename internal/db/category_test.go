@@ -0,0 +1,54 @@
+package db
+
+import "testing"
+
+func TestCategorySelectAllCountsLivePosts(t *testing.T) {
+	s := testStore(t)
+	author := testUser(t, s, "author")
+	general, err := s.CategoryIDWithName("general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tech, err := s.CategoryIDWithName("Tech")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if general == 0 || tech == 0 {
+		t.Fatalf("seeded categories not found: %d, %d", general, tech)
+	}
+	if id, err := s.CategoryIDWithName("Cooking"); err != nil || id != 0 {
+		t.Errorf("unknown category: %d, %v", id, err)
+	}
+
+	if _, err := s.PostInsert(author, "both", "body", []int{general, tech}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PostInsert(author, "tech", "body", []int{tech}); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.PostInsert(author, "removed", "body", []int{tech})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PostDelete(removed.ID, author); err != nil {
+		t.Fatal(err)
+	}
+
+	categories, err := s.CategorySelectAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, c := range categories {
+		counts[c.Name] = c.PostCount
+	}
+	want := map[string]int{"General": 1, "Tech": 2, "Gaming": 0, "Music": 0, "Off-topic": 0}
+	if len(counts) != len(want) {
+		t.Errorf("categories %v, want %v", counts, want)
+	}
+	for name, count := range want {
+		if counts[name] != count {
+			t.Errorf("%s: %d posts, want %d", name, counts[name], count)
+		}
+	}
+}
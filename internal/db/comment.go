@@ -7,34 +7,18 @@ import (
 	"time"
 )
 
-func createCommentsTable(db *sql.DB) {
-	createTableSQL := `CREATE TABLE IF NOT EXISTS "comment" (
-	"id"	INTEGER NOT NULL UNIQUE,
-	"user_id"	INTEGER NOT NULL,
-	"user"	TEXT NOT NULL,
-	"post_id"	INTEGER NOT NULL,
-	"body"	TEXT NOT NULL,
-	"createdAt"	NUMERIC DEFAULT CURRENT_TIMESTAMP,
-	"updatedAt"	NUMERIC DEFAULT CURRENT_TIMESTAMP,
-	PRIMARY KEY("id" AUTOINCREMENT),
-	FOREIGN KEY("post_id") REFERENCES "post"("id"),
-	FOREIGN KEY("user_id") REFERENCES "User"("id")
-)`
-	executeSQL(db, createTableSQL)
-}
-
 // Create - Insert a new comment
-func CommentInsert(userID int, uuid string, postID int, body string) (*models.Comment, error) {
-	db := SetupDatabase()
-	defer db.Close()
+func (s *Store) CommentInsert(userID int, postID int, body string) (*models.Comment, error) {
+	// Resolve the nickname before opening the transaction so the lookup
+	// doesn't wait on the connection the transaction is holding
+	user := s.UserNicknameWithID(userID)
 
-	tx, err := db.Begin()
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	user := UserNicknameWithUUID(uuid)
-	now := time.Now().Format("2006-01-02 15:04:05") // Fix date format
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
 
 	// Match the column names in your 'comment' table
 	insertSQL := `INSERT INTO comment (user_id, user, post_id, body, createdAt) 
@@ -68,25 +52,34 @@ func CommentInsert(userID int, uuid string, postID int, body string) (*models.Co
 	return comment, nil
 }
 
-// Read - Get comment by ID
-func CommentSelectByID(commentID int) (*models.Comment, error) {
-	db := SetupDatabase()
-	defer db.Close()
+// Read - Get comment by ID, if neither it nor its post is deleted
+func (s *Store) CommentSelectByID(commentID int) (*models.Comment, error) {
+	return s.commentSelectByID(commentID, false)
+}
 
-	tx, err := db.Begin()
+// Read - Get a deleted comment of a post that isn't, e.g. to restore it.
+// Comments deleted with their author's account only come back with it.
+func (s *Store) CommentSelectDeletedByID(commentID int) (*models.Comment, error) {
+	return s.commentSelectByID(commentID, true)
+}
+
+func (s *Store) commentSelectByID(commentID int, deleted bool) (*models.Comment, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	query := `SELECT id, user_id, user, post_id, body, createdAt, updatedAt
-             FROM comment WHERE id = ?`
+	query := `SELECT c.id, c.user_id, c.user, c.post_id, c.body, c.createdAt, c.updatedAt,
+                 (SELECT COUNT(*) FROM comment_revision r WHERE r.comment_id = c.id), COALESCE(c.deleted_by, 0)
+             FROM comment c JOIN post p ON p.id = c.post_id AND p.deleted_at IS NULL
+             WHERE c.id = ? AND (c.deleted_at IS NOT NULL) = ? AND c.deleted_with_user IS NULL`
 
 	var comment models.Comment
 	var createdAtStr, updatedAtStr string
 
-	err = tx.QueryRow(query, commentID).Scan(
+	err = tx.QueryRow(query, commentID, deleted).Scan(
 		&comment.ID, &comment.UserID, &comment.Username, &comment.PostID, &comment.Body,
-		&createdAtStr, &updatedAtStr,
+		&createdAtStr, &updatedAtStr, &comment.Revisions, &comment.DeletedBy,
 	)
 
 	if err != nil {
@@ -108,18 +101,17 @@ func CommentSelectByID(commentID int) (*models.Comment, error) {
 	return &comment, nil
 }
 
-// Read - Get comments by post ID
-func CommentSelectByPostID(postID int) ([]*models.Comment, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+// Read - Get comments by post ID. Deleted comments keep their place in the
+// thread as placeholders, without their author or content.
+func (s *Store) CommentSelectByPostID(postID int) ([]*models.Comment, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	query := `SELECT id, user_id, user, post_id, body, createdAt, updatedAt
-             FROM comment WHERE post_id = ? ORDER BY createdAt ASC`
+	query := `SELECT c.id, c.user_id, c.user, c.post_id, c.body, c.createdAt, c.updatedAt,
+                 (SELECT COUNT(*) FROM comment_revision r WHERE r.comment_id = c.id), c.deleted_at IS NOT NULL
+             FROM comment c WHERE c.post_id = ? ORDER BY c.createdAt ASC`
 
 	rows, err := tx.Query(query, postID)
 	if err != nil {
@@ -134,7 +126,7 @@ func CommentSelectByPostID(postID int) ([]*models.Comment, error) {
 		var createdAtStr, updatedAtStr string
 
 		if err := rows.Scan(&comment.ID, &comment.UserID, &comment.Username, &comment.PostID, &comment.Body,
-			&createdAtStr, &updatedAtStr); err != nil {
+			&createdAtStr, &updatedAtStr, &comment.Revisions, &comment.Deleted); err != nil {
 			tx.Rollback()
 			return nil, fmt.Errorf("error scanning comment: %v", err)
 		}
@@ -142,6 +134,9 @@ func CommentSelectByPostID(postID int) ([]*models.Comment, error) {
 		// Parse time strings
 		comment.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAtStr)
 		comment.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAtStr)
+		if comment.Deleted {
+			comment = DeletedComment(comment)
+		}
 
 		comments = append(comments, comment)
 	}
@@ -159,17 +154,16 @@ func CommentSelectByPostID(postID int) ([]*models.Comment, error) {
 }
 
 // Read - Get comments by user ID
-func CommentSelectByUserID(userID int) ([]*models.Comment, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) CommentSelectByUserID(userID int) ([]*models.Comment, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	query := `SELECT id, user_id, user, post_id, body, createdAt, updatedAt
-             FROM comment WHERE user_id = ? ORDER BY createdAt DESC`
+	query := `SELECT c.id, c.user_id, c.user, c.post_id, c.body, c.createdAt, c.updatedAt
+             FROM comment c JOIN post p ON p.id = c.post_id
+             WHERE c.user_id = ? AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+             ORDER BY c.createdAt DESC`
 
 	rows, err := tx.Query(query, userID)
 	if err != nil {
@@ -208,54 +202,68 @@ func CommentSelectByUserID(userID int) ([]*models.Comment, error) {
 	return comments, nil
 }
 
-// Update - Update comment
-func CommentUpdate(commentID int, body string) error {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+// Update - Update comment, keeping the previous version in its history.
+// Returns false, leaving the comment alone, when nothing changes.
+func (s *Store) CommentUpdate(commentID, editorID int, body string) (bool, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return false, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	now := time.Now().Format("2006-01-02 15:04:05")
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	changed, err := commentRevisionInsert(tx, commentID, editorID, body, now)
+	if err != nil || !changed {
+		tx.Rollback()
+		return false, err
+	}
 
 	updateSQL := `UPDATE comment SET body=?, updatedAt=? WHERE id=?`
 	_, err = tx.Exec(updateSQL, body, now, commentID)
 
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error executing statement: %v", err)
+		return false, fmt.Errorf("error executing statement: %v", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return false, fmt.Errorf("error committing transaction: %v", err)
 	}
 
-	return nil
+	return true, nil
 }
 
-// Delete - Delete comment
-func CommentDelete(commentID int) error {
-	db := SetupDatabase()
-	defer db.Close()
+// Delete - Mark a comment as deleted; its thread shows a placeholder instead
+func (s *Store) CommentDelete(commentID, deletedBy int) error {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
 
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+	updateSQL := `UPDATE comment SET deleted_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`
+	if _, err := s.DB.Exec(updateSQL, now, deletedBy, commentID); err != nil {
+		return fmt.Errorf("error deleting comment: %v", err)
 	}
 
-	deleteSQL := `DELETE FROM comment WHERE id=?`
-	_, err = tx.Exec(deleteSQL, commentID)
-
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("error executing statement: %v", err)
-	}
+	return nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+// Update - Bring back a deleted comment
+func (s *Store) CommentRestore(commentID int) error {
+	updateSQL := `UPDATE comment SET deleted_at = NULL, deleted_by = NULL WHERE id = ?`
+	if _, err := s.DB.Exec(updateSQL, commentID); err != nil {
+		return fmt.Errorf("error restoring comment: %v", err)
 	}
 
 	return nil
 }
+
+// DeletedComment is the placeholder standing for a deleted comment in its
+// thread: its place is kept, not its author or content
+func DeletedComment(comment *models.Comment) *models.Comment {
+	return &models.Comment{
+		ID:        comment.ID,
+		PostID:    comment.PostID,
+		Username:  DeletedPlaceholder,
+		Body:      DeletedPlaceholder,
+		CreatedAt: comment.CreatedAt,
+		Deleted:   true,
+	}
+}
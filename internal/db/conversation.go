@@ -0,0 +1,72 @@
+package db
+
+import (
+	"fmt"
+	"models"
+)
+
+// Longest preview of the last message shown in the conversation list
+const conversationPreviewLength = 80
+
+// ConversationList returns every user the given user exchanged private
+// messages with, most recent conversation first
+func (s *Store) ConversationList(userID int) ([]models.Conversation, error) {
+	return s.conversations(userID, 0)
+}
+
+// Conversation returns the conversation list entry of userID with partnerID
+func (s *Store) Conversation(userID, partnerID int) (*models.Conversation, error) {
+	conversations, err := s.conversations(userID, partnerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, fmt.Errorf("no conversation between users %d and %d", userID, partnerID)
+	}
+	return &conversations[0], nil
+}
+
+// conversations loads the conversation list of a user, restricted to one
+// partner unless partnerID is 0
+func (s *Store) conversations(userID, partnerID int) ([]models.Conversation, error) {
+	query := `SELECT u.nickName, pm.id, CASE WHEN pm.deleted_at IS NULL THEN pm.message ELSE ? END,
+              s.nickName, pm.createdAt,
+              (SELECT COUNT(*) FROM private_message
+               WHERE receiver_id = ? AND sender_id = c.partner_id AND read = 0 AND deleted_at IS NULL)
+              FROM (SELECT CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END AS partner_id,
+                           MAX(id) AS last_id
+                    FROM private_message
+                    WHERE sender_id = ? OR receiver_id = ?
+                    GROUP BY partner_id) c
+              JOIN private_message pm ON pm.id = c.last_id
+              JOIN user u ON u.id = c.partner_id AND u.deleted_at IS NULL
+              JOIN user s ON s.id = pm.sender_id
+              WHERE (? = 0 OR c.partner_id = ?)
+              ORDER BY pm.id DESC`
+
+	rows, err := s.DB.Query(query, DeletedPlaceholder, userID, userID, userID, userID, partnerID, partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		var c models.Conversation
+		if err := rows.Scan(&c.User, &c.LastMessageID, &c.LastMessage, &c.LastSender,
+			&c.Timestamp, &c.Unread); err != nil {
+			return nil, fmt.Errorf("error scanning conversation: %v", err)
+		}
+		if preview := []rune(c.LastMessage); len(preview) > conversationPreviewLength {
+			c.LastMessage = string(preview[:conversationPreviewLength]) + "…"
+		}
+
+		conversations = append(conversations, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating conversations: %v", err)
+	}
+
+	return conversations, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import "testing"
+
+// conversationUsers lists the partners of a user's conversations, in order,
+// with the number of messages of each the user hasn't read
+func conversationUsers(t *testing.T, s *Store, userID int) ([]string, map[string]int) {
+	t.Helper()
+
+	conversations, err := s.ConversationList(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := make([]string, 0, len(conversations))
+	unread := map[string]int{}
+	for _, c := range conversations {
+		users = append(users, c.User)
+		unread[c.User] = c.Unread
+	}
+	return users, unread
+}
+
+func TestConversationListPutsTheLatestFirst(t *testing.T) {
+	s := testStore(t)
+	alice := testUser(t, s, "alice")
+	bob := testUser(t, s, "bob")
+	carol := testUser(t, s, "carol")
+	testUser(t, s, "dave") // Never talked to alice
+
+	testMessages(t, s, bob, alice, 1)
+	testMessages(t, s, alice, carol, 1)
+	users, _ := conversationUsers(t, s, alice)
+	if len(users) != 2 || users[0] != "carol" || users[1] != "bob" {
+		t.Errorf("conversations %v, want [carol bob]", users)
+	}
+
+	// Answering bob brings him back on top, whoever wrote last
+	testMessages(t, s, alice, bob, 1)
+	users, _ = conversationUsers(t, s, alice)
+	if len(users) != 2 || users[0] != "bob" || users[1] != "carol" {
+		t.Errorf("conversations %v, want [bob carol]", users)
+	}
+
+	conversation, err := s.Conversation(alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.LastSender != "alice" || conversation.LastMessage != "message 1" {
+		t.Errorf("last message %q from %s", conversation.LastMessage, conversation.LastSender)
+	}
+	if _, err := s.Conversation(alice, alice); err == nil {
+		t.Error("found a conversation that doesn't exist")
+	}
+}
+
+func TestConversationListCountsWhatIsUnread(t *testing.T) {
+	s := testStore(t)
+	alice := testUser(t, s, "alice")
+	bob := testUser(t, s, "bob")
+	carol := testUser(t, s, "carol")
+
+	fromBob := testMessages(t, s, bob, alice, 3)
+	testMessages(t, s, carol, alice, 2)
+	testMessages(t, s, alice, bob, 1) // Her own messages aren't unread to her
+	if _, err := s.PrivateMessageDelete(fromBob[2], bob); err != nil {
+		t.Fatal(err)
+	}
+
+	_, unread := conversationUsers(t, s, alice)
+	if unread["bob"] != 2 || unread["carol"] != 2 {
+		t.Errorf("unread %v, want bob 2 and carol 2", unread)
+	}
+	if _, unread = conversationUsers(t, s, bob); unread["alice"] != 1 {
+		t.Errorf("bob's unread %v, want alice 1", unread)
+	}
+
+	// Reading bob's conversation up to his first message
+	if _, err := s.PrivateMessageMarkRead(alice, bob, fromBob[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, unread = conversationUsers(t, s, alice); unread["bob"] != 1 || unread["carol"] != 2 {
+		t.Errorf("unread after reading %v, want bob 1 and carol 2", unread)
+	}
+}
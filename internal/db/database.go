@@ -1 +1,14 @@
 package db
+
+import "database/sql"
+
+// Store owns the connection pool shared by every repository function.
+// It is opened once at startup and handed to whoever needs the database.
+type Store struct {
+	DB *sql.DB
+}
+
+// Close releases every connection held by the pool
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Create - Record a verification email about to be sent to an address for a
+// user, unless the user or the address already had as many as allowed since
+// the given time. Like PasswordResetRequestInsert, the check and the insert
+// are one statement. Returns false when the email must not be sent.
+func (s *Store) EmailVerificationRequestInsert(userID int, email string, since time.Time, maxPerUser, maxPerEmail int) (bool, error) {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	cutoff := since.UTC().Format("2006-01-02 15:04:05")
+	email = strings.ToLower(email)
+
+	insertSQL := `INSERT INTO email_verification_request (user_id, email, created_at)
+                  SELECT ?, ?, ?
+                  WHERE (SELECT COUNT(*) FROM email_verification_request WHERE user_id = ? AND created_at > ?) < ?
+                  AND (SELECT COUNT(*) FROM email_verification_request WHERE email = ? AND created_at > ?) < ?`
+	result, err := s.DB.Exec(insertSQL, userID, email, now,
+		userID, cutoff, maxPerUser, email, cutoff, maxPerEmail)
+	if err != nil {
+		return false, fmt.Errorf("error inserting email verification request: %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error reading affected rows: %v", err)
+	}
+	return inserted == 1, nil
+}
+
+// Create - Record an email verification token for the address a user has
+// now. Links sent before stop working.
+func (s *Store) EmailVerificationInsert(userID int, email, tokenHash string, expiresAt time.Time) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	if _, err = tx.Exec(`DELETE FROM email_verification WHERE user_id = ? AND used_at IS NULL`, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting previous tokens: %v", err)
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	insertSQL := `INSERT INTO email_verification (user_id, email, token_hash, created_at, expires_at)
+                  VALUES (?, ?, ?, ?, ?)`
+	_, err = tx.Exec(insertSQL, userID, email, tokenHash, now, expiresAt.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error inserting token: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Update - Use up an email verification token and mark the user's email as
+// verified. Fails if the token is unknown, expired, already used, or was
+// sent to an address the user has changed since.
+func (s *Store) EmailVerificationConsume(tokenHash string) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	var id, userID int
+	query := `SELECT v.id, v.user_id FROM email_verification v
+              JOIN user u ON v.user_id = u.id
+              WHERE v.token_hash = ? AND v.used_at IS NULL AND v.expires_at > ? AND v.email = u.email`
+	if err = tx.QueryRow(query, tokenHash, now).Scan(&id, &userID); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("invalid or expired token")
+		}
+		return 0, fmt.Errorf("error executing query: %v", err)
+	}
+
+	if _, err = tx.Exec(`UPDATE email_verification SET used_at = ? WHERE id = ?`, now, id); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("error executing statement: %v", err)
+	}
+	if _, err = tx.Exec(`UPDATE user SET email_verified_at = ? WHERE id = ?`, now, userID); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("error executing statement: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmailVerificationConsumeRefusesStaleTokens(t *testing.T) {
+	s := testStore(t)
+	userID := testUser(t, s, "member")
+	later := time.Now().Add(time.Hour)
+
+	// Expired
+	if err := s.EmailVerificationInsert(userID, "member@example.com", "expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EmailVerificationConsume("expired"); err == nil {
+		t.Error("an expired token was accepted")
+	}
+
+	// Used twice
+	if err := s.EmailVerificationInsert(userID, "member@example.com", "once", later); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.EmailVerificationConsume("once"); err != nil || id != userID {
+		t.Fatalf("first use: user %d, %v", id, err)
+	}
+	if _, err := s.EmailVerificationConsume("once"); err == nil {
+		t.Error("a used token was accepted again")
+	}
+	user, err := s.UserSelectByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.EmailVerified {
+		t.Fatal("the email isn't verified after using the token")
+	}
+
+	// Sent to the address the user had before changing it
+	if err := s.EmailVerificationInsert(userID, "member@example.com", "old-address", later); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UserUpdateProfile(userID, "member", "Other", "First", "Last", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EmailVerificationConsume("old-address"); err == nil {
+		t.Error("a token sent to the previous address was accepted")
+	}
+	if user, err = s.UserSelectByID(userID); err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified {
+		t.Error("the new address counts as verified")
+	}
+}
+
+func TestEmailVerificationRequestInsertLimitsUsersAndAddresses(t *testing.T) {
+	s := testStore(t)
+	first := testUser(t, s, "first")
+	second := testUser(t, s, "second")
+	since := time.Now().Add(-time.Hour)
+
+	for i := 1; i <= 3; i++ {
+		allowed, err := s.EmailVerificationRequestInsert(first, "first@example.com", since, 2, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != (i <= 2) {
+			t.Errorf("request %d of the user: allowed %v", i, allowed)
+		}
+	}
+
+	// Another account asking for the same address, whatever its case
+	for i := 1; i <= 2; i++ {
+		allowed, err := s.EmailVerificationRequestInsert(second, "First@Example.com", since, 2, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != (i == 1) {
+			t.Errorf("request %d of the address: allowed %v", i, allowed)
+		}
+	}
+
+	// Requests from before the window don't count
+	allowed, err := s.EmailVerificationRequestInsert(first, "other@example.com", time.Now().Add(time.Minute), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !allowed {
+		t.Error("old requests still count")
+	}
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"models"
+	"strconv"
+	"strings"
+)
+
+// Orders the post feed can be sorted in
+const (
+	FeedNewest        = "newest"   // Latest posts first
+	FeedMostCommented = "comments" // Posts with the most comments first
+	FeedActive        = "active"   // Posts with the latest post or comment first
+)
+
+// Size of a feed page when none is asked, and the largest one served
+const (
+	DefaultFeedPageSize = 20
+	MaxFeedPageSize     = 50
+)
+
+// postCommentCountColumn counts the comments of the post aliased p, deleted
+// ones left out
+const postCommentCountColumn = `(SELECT COUNT(*) FROM comment c WHERE c.post_id = p.id AND c.deleted_at IS NULL)`
+
+// feedSortKeys computes, for the post aliased p, the value each order sorts
+// on. Posts sharing a value are ordered by ID.
+var feedSortKeys = map[string]string{
+	FeedNewest:        `p.id`,
+	FeedMostCommented: postCommentCountColumn,
+	FeedActive: `MAX(COALESCE(CAST(strftime('%s', p.createdAt) AS INTEGER), 0),
+	                 COALESCE((SELECT MAX(CAST(strftime('%s', c.createdAt) AS INTEGER))
+	                           FROM comment c WHERE c.post_id = p.id AND c.deleted_at IS NULL), 0))`,
+}
+
+// ValidFeedSort reports whether sort is one of the feed orders
+func ValidFeedSort(sort string) bool {
+	_, ok := feedSortKeys[sort]
+	return ok
+}
+
+// FeedCursor is the position of a post in the feed sorted a given way
+type FeedCursor struct {
+	Sort string
+	Key  int64
+	ID   int
+}
+
+// String encodes the cursor as the opaque value handed to the frontend
+func (c FeedCursor) String() string {
+	raw := fmt.Sprintf("%s:%d:%d", c.Sort, c.Key, c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseFeedCursor decodes a cursor made by FeedCursor.String
+func ParseFeedCursor(value string) (FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return FeedCursor{}, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || !ValidFeedSort(parts[0]) {
+		return FeedCursor{}, fmt.Errorf("invalid cursor")
+	}
+	key, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return FeedCursor{}, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return FeedCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return FeedCursor{Sort: parts[0], Key: key, ID: id}, nil
+}
+
+// FeedQuery selects a page of the feed. With neither Before nor After it is
+// the first page.
+type FeedQuery struct {
+	Sort       string
+	CategoryID int         // 0 for every category
+	Before     *FeedCursor // The posts that come after this one in the feed
+	After      *FeedCursor // The posts that come before this one, e.g. new ones
+	Limit      int
+}
+
+// Read - Get one page of the post feed, in feed order. HasMore tells whether
+// more posts follow in the direction asked, NextCursor continues down the
+// feed and PrevCursor asks for the posts above the page.
+func (s *Store) PostSelectFeed(q FeedQuery) (*models.FeedPage, error) {
+	key, ok := feedSortKeys[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", q.Sort)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultFeedPageSize
+	} else if q.Limit > MaxFeedPageSize {
+		q.Limit = MaxFeedPageSize
+	}
+
+	// Walking up the feed from After reads it backwards
+	condition, order := `1`, `DESC`
+	args := []any{q.CategoryID, q.CategoryID}
+	if q.Before != nil {
+		condition = `(sort_key, id) < (?, ?)`
+		args = append(args, q.Before.Key, q.Before.ID)
+	} else if q.After != nil {
+		condition, order = `(sort_key, id) > (?, ?)`, `ASC`
+		args = append(args, q.After.Key, q.After.ID)
+	}
+
+	query := `SELECT id, user_id, user, title, body, status, createdAt, updatedAt, categories, comment_count, revisions, sort_key
+             FROM (
+                 SELECT p.id, p.user_id, p.user, p.title, p.body, p.status, p.createdAt, p.updatedAt,
+                     ` + postCategoriesColumn + ` AS categories,
+                     ` + postCommentCountColumn + ` AS comment_count,
+                     (SELECT COUNT(*) FROM post_revision r WHERE r.post_id = p.id) AS revisions,
+                     ` + key + ` AS sort_key
+                 FROM post p
+                 WHERE p.deleted_at IS NULL
+                     AND (? = 0 OR EXISTS (SELECT 1 FROM post_category pc WHERE pc.post_id = p.id AND pc.category_id = ?))
+             )
+             WHERE ` + condition + `
+             ORDER BY sort_key ` + order + `, id ` + order + `
+             LIMIT ?`
+
+	// Fetch one extra row to know whether another page exists
+	rows, err := s.DB.Query(query, append(args, q.Limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying posts: %v", err)
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	var keys []int64
+	for rows.Next() {
+		var post models.Post
+		var createdAt, updatedAt sql.NullTime
+		var categories sql.NullString
+		var sortKey int64
+
+		if err := rows.Scan(&post.ID, &post.UserID, &post.Username, &post.Title, &post.Body, &post.Status,
+			&createdAt, &updatedAt, &categories, &post.CommentCount, &post.Revisions, &sortKey); err != nil {
+			return nil, fmt.Errorf("error scanning post: %v", err)
+		}
+		post.CreatedAt = createdAt.Time
+		post.UpdatedAt = updatedAt.Time
+		post.Categories = splitCategories(categories)
+
+		posts = append(posts, post)
+		keys = append(keys, sortKey)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	page := &models.FeedPage{Posts: posts}
+	if len(posts) > q.Limit {
+		page.HasMore = true
+		page.Posts, keys = posts[:q.Limit], keys[:q.Limit]
+	}
+	if q.After != nil {
+		// Back in feed order
+		for i, j := 0, len(page.Posts)-1; i < j; i, j = i+1, j-1 {
+			page.Posts[i], page.Posts[j] = page.Posts[j], page.Posts[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	cursor := func(i int) string {
+		return FeedCursor{Sort: q.Sort, Key: keys[i], ID: page.Posts[i].ID}.String()
+	}
+	if n := len(page.Posts); n > 0 {
+		page.PrevCursor = cursor(0)
+		// Going up from After, the posts below are already known to exist
+		if page.HasMore || q.After != nil {
+			page.NextCursor = cursor(n - 1)
+		}
+	} else if q.After != nil {
+		// Nothing new yet, ask again from the same place
+		page.PrevCursor = q.After.String()
+	}
+
+	return page, nil
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestFeedCursorRoundTrip(t *testing.T) {
+	for _, cursor := range []FeedCursor{
+		{Sort: FeedNewest, Key: 42, ID: 42},
+		{Sort: FeedMostCommented, Key: 0, ID: 7},
+		{Sort: FeedActive, Key: 1760000000, ID: 1},
+	} {
+		parsed, err := ParseFeedCursor(cursor.String())
+		if err != nil {
+			t.Errorf("ParseFeedCursor(%+v): %v", cursor, err)
+			continue
+		}
+		if parsed != cursor {
+			t.Errorf("round trip of %+v gave %+v", cursor, parsed)
+		}
+	}
+}
+
+func TestParseFeedCursorRejectsForgedValues(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	for _, value := range []string{
+		"",
+		"not a cursor!",
+		encode("newest:1"),
+		encode("newest:1:2:3"),
+		encode("oldest:1:2"),
+		encode("newest:one:2"),
+		encode("newest:1:two"),
+	} {
+		if cursor, err := ParseFeedCursor(value); err == nil {
+			t.Errorf("ParseFeedCursor(%q) = %+v, want an error", value, cursor)
+		}
+	}
+}
+
+// feedIDs returns the IDs of the posts of a feed page, in order
+func feedIDs(t *testing.T, s *Store, q FeedQuery) ([]int, string, string, bool) {
+	t.Helper()
+
+	page, err := s.PostSelectFeed(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, post := range page.Posts {
+		ids = append(ids, post.ID)
+	}
+	return ids, page.PrevCursor, page.NextCursor, page.HasMore
+}
+
+func cursorOf(t *testing.T, value string) *FeedCursor {
+	t.Helper()
+
+	cursor, err := ParseFeedCursor(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cursor
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPostSelectFeedWalksThePagesBothWays(t *testing.T) {
+	s := testStore(t)
+	author := testUser(t, s, "author")
+	var posts []int
+	for _, title := range []string{"one", "two", "three", "four", "five"} {
+		posts = append(posts, testPost(t, s, author, title))
+	}
+
+	first, top, next, more := feedIDs(t, s, FeedQuery{Sort: FeedNewest, Limit: 2})
+	if want := []int{posts[4], posts[3]}; !equalIDs(first, want) || !more {
+		t.Fatalf("first page = %v (more %v), want %v", first, more, want)
+	}
+
+	second, _, next, more := feedIDs(t, s, FeedQuery{Sort: FeedNewest, Limit: 2, Before: cursorOf(t, next)})
+	if want := []int{posts[2], posts[1]}; !equalIDs(second, want) || !more {
+		t.Fatalf("second page = %v (more %v), want %v", second, more, want)
+	}
+
+	last, _, _, more := feedIDs(t, s, FeedQuery{Sort: FeedNewest, Limit: 2, Before: cursorOf(t, next)})
+	if want := []int{posts[0]}; !equalIDs(last, want) || more {
+		t.Fatalf("last page = %v (more %v), want %v", last, more, want)
+	}
+
+	// Nothing above the first page until someone posts
+	above, same, _, _ := feedIDs(t, s, FeedQuery{Sort: FeedNewest, Limit: 2, After: cursorOf(t, top)})
+	if len(above) != 0 || same != top {
+		t.Fatalf("above the first page = %v, cursor %q, want nothing and %q", above, same, top)
+	}
+	newest := testPost(t, s, author, "six")
+	above, _, _, _ = feedIDs(t, s, FeedQuery{Sort: FeedNewest, Limit: 2, After: cursorOf(t, top)})
+	if want := []int{newest}; !equalIDs(above, want) {
+		t.Errorf("above the first page = %v, want %v", above, want)
+	}
+}
+
+func TestPostSelectFeedSortsByComments(t *testing.T) {
+	s := testStore(t)
+	author := testUser(t, s, "author")
+	quiet := testPost(t, s, author, "quiet")
+	busy := testPost(t, s, author, "busy")
+	some := testPost(t, s, author, "some")
+	for post, comments := range map[int]int{busy: 3, some: 1} {
+		for i := 0; i < comments; i++ {
+			if _, err := s.CommentInsert(author, post, "comment"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	ids, _, _, _ := feedIDs(t, s, FeedQuery{Sort: FeedMostCommented})
+	if want := []int{busy, some, quiet}; !equalIDs(ids, want) {
+		t.Errorf("feed by comments = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoginFailures counts the recent failed logins of an address and of an
+// account, and tells when the last one happened
+type LoginFailures struct {
+	IP          int
+	IPLast      time.Time
+	Account     int
+	AccountLast time.Time
+}
+
+// Create - Record a login attempt as failed before its password is checked,
+// unless retryAfter finds, from the failures since the given time, that the
+// address or the account must still wait. The attempt is written first, so
+// the transaction holds the write lock while counting and concurrent attempts
+// can't all pass the check. userID is 0 when the login matched no account.
+// Returns the ID of the attempt, or how long to wait when it was refused.
+func (s *Store) LoginAttemptStart(login string, userID int, ip, userAgent string, since time.Time,
+	retryAfter func(LoginFailures) time.Duration) (int, time.Duration, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	var user sql.NullInt64
+	if userID != 0 {
+		user = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	login = strings.ToLower(login)
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	insertSQL := `INSERT INTO login_attempt (login, user_id, ip, user_agent, success, created_at)
+                  VALUES (?, ?, ?, ?, 0, ?)`
+	result, err := tx.Exec(insertSQL, login, user, ip, userAgent, now)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("error inserting login attempt: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("error getting login attempt id: %v", err)
+	}
+
+	// Failures of the address
+	cutoff := since.UTC().Format("2006-01-02 15:04:05")
+	var failures LoginFailures
+	query := `SELECT COUNT(*), MAX(created_at) FROM login_attempt
+              WHERE ip = ? AND success = 0 AND created_at > ? AND id <> ?`
+	failures.IP, failures.IPLast, err = loginFailures(tx, query, ip, cutoff, id)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	// Failures of the account since its last successful login, or of the
+	// login when it matches no account
+	column, key := "user_id", interface{}(userID)
+	if userID == 0 {
+		column, key = "login", login
+	}
+	query = `SELECT COUNT(*), MAX(created_at) FROM login_attempt
+             WHERE ` + column + ` = ? AND success = 0 AND created_at > ? AND id <> ?
+             AND id > COALESCE((SELECT MAX(id) FROM login_attempt
+                                 WHERE ` + column + ` = ? AND success = 1), 0)`
+	failures.Account, failures.AccountLast, err = loginFailures(tx, query, key, cutoff, id, key)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	if wait := retryAfter(failures); wait > 0 {
+		tx.Rollback()
+		return 0, wait, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return int(id), 0, nil
+}
+
+// Update - Mark a login attempt as successful, which starts the account's
+// count of failures over
+func (s *Store) LoginAttemptSucceeded(id int) error {
+	_, err := s.DB.Exec(`UPDATE login_attempt SET success = 1 WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error updating login attempt: %v", err)
+	}
+
+	return nil
+}
+
+// loginFailures runs a COUNT(*), MAX(created_at) query on login_attempt
+func loginFailures(tx *sql.Tx, query string, args ...interface{}) (int, time.Time, error) {
+	var count int
+	var last sql.NullString
+	if err := tx.QueryRow(query, args...).Scan(&count, &last); err != nil {
+		return 0, time.Time{}, fmt.Errorf("error executing query: %v", err)
+	}
+
+	var lastAt time.Time
+	if last.Valid {
+		lastAt, _ = time.Parse("2006-01-02 15:04:05", last.String)
+	}
+	return count, lastAt, nil
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptStartLetsOnlyTheAllowedAttemptsThrough(t *testing.T) {
+	s := testStore(t)
+	userID := testUser(t, s, "target")
+
+	const allowed = 3
+	retryAfter := func(failures LoginFailures) time.Duration {
+		if failures.Account >= allowed {
+			return time.Minute
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	started := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, wait, err := s.LoginAttemptStart("target", userID, "10.0.0.1", "test", time.Now().Add(-time.Hour), retryAfter)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if wait == 0 && id != 0 {
+				mu.Lock()
+				started++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if started != allowed {
+		t.Errorf("%d concurrent attempts got through, want %d", started, allowed)
+	}
+}
+
+func TestLoginAttemptSucceededStartsTheCountOver(t *testing.T) {
+	s := testStore(t)
+	userID := testUser(t, s, "member")
+	since := time.Now().Add(-time.Hour)
+
+	var seen LoginFailures
+	record := func(failures LoginFailures) time.Duration {
+		seen = failures
+		return 0
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := s.LoginAttemptStart("member", userID, "10.0.0.1", "test", since, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, _, err := s.LoginAttemptStart("member", userID, "10.0.0.1", "test", since, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen.Account != 2 || seen.IP != 2 {
+		t.Fatalf("failures before the third attempt = %+v, want 2 and 2", seen)
+	}
+
+	if err := s.LoginAttemptSucceeded(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.LoginAttemptStart("member", userID, "10.0.0.1", "test", since, record); err != nil {
+		t.Fatal(err)
+	}
+	if seen.Account != 0 {
+		t.Errorf("account failures after a success = %d, want 0", seen.Account)
+	}
+}
@@ -0,0 +1,78 @@
+package migrations
+
+// initialSchema captures the tables the forum started with. Every statement
+// uses IF NOT EXISTS so databases created before migrations existed simply
+// get this version recorded.
+var initialSchema = Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: `
+CREATE TABLE IF NOT EXISTS "user" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"uuid"  TEXT NOT NULL UNIQUE,
+	"nickName"	TEXT NOT NULL UNIQUE,
+	"gender"	TEXT NOT NULL,
+	"firstName"	TEXT NOT NULL,
+	"lastName"	TEXT NOT NULL,
+	"email"	TEXT NOT NULL UNIQUE,
+	"password"	TEXT NOT NULL,
+	"role"	TEXT NOT NULL,
+	"connected" INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("id" AUTOINCREMENT));
+
+CREATE TABLE IF NOT EXISTS "post" (
+    "id"    INTEGER NOT NULL UNIQUE,
+    "user_id"    TEXT NOT NULL,
+	"user"	TEXT NOT NULL,
+    "title"    TEXT NOT NULL,
+    "body"    TEXT NOT NULL,
+    "createdAt"    DATETIME DEFAULT CURRENT_TIMESTAMP,
+    "updatedAt"    DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id" AUTOINCREMENT),
+    FOREIGN KEY("user_id") REFERENCES "User"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "comment" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"user_id"	INTEGER NOT NULL,
+	"user"	TEXT NOT NULL,
+	"post_id"	INTEGER NOT NULL,
+	"body"	TEXT NOT NULL,
+	"createdAt"	NUMERIC DEFAULT CURRENT_TIMESTAMP,
+	"updatedAt"	NUMERIC DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY("post_id") REFERENCES "post"("id"),
+	FOREIGN KEY("user_id") REFERENCES "User"("id")
+);
+
+CREATE TABLE IF NOT EXISTS notification (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	sender_id INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	content TEXT NOT NULL,
+	related_id INTEGER NOT NULL,
+	read BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES "user"(id),
+	FOREIGN KEY (sender_id) REFERENCES "user"(id)
+);
+
+CREATE TABLE IF NOT EXISTS "private_message" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"sender_id"	INTEGER NOT NULL,
+	"receiver_id"	INTEGER NOT NULL,
+	"message"	TEXT NOT NULL,
+	"createdAt"	NUMERIC DEFAULT CURRENT_TIMESTAMP,
+	"read"	INTEGER DEFAULT 0,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY("sender_id") REFERENCES "User"("id"),
+	FOREIGN KEY("receiver_id") REFERENCES "User"("id")
+);`,
+	Down: `
+DROP TABLE IF EXISTS "private_message";
+DROP TABLE IF EXISTS notification;
+DROP TABLE IF EXISTS "comment";
+DROP TABLE IF EXISTS "post";
+DROP TABLE IF EXISTS "user";`,
+}
@@ -0,0 +1,9 @@
+package migrations
+
+// postStatus adds the status column models.Post already carries
+var postStatus = Migration{
+	Version: 2,
+	Name:    "post_status",
+	Up:      `ALTER TABLE "post" ADD COLUMN "status" TEXT NOT NULL DEFAULT 'published';`,
+	Down:    `ALTER TABLE "post" DROP COLUMN "status";`,
+}
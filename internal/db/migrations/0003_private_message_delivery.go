@@ -0,0 +1,15 @@
+package migrations
+
+// privateMessageDelivery tracks when a private message reached its receiver.
+// Messages sent before this existed are considered delivered.
+var privateMessageDelivery = Migration{
+	Version: 3,
+	Name:    "private_message_delivery",
+	Up: `
+ALTER TABLE "private_message" ADD COLUMN "delivered_at" DATETIME;
+UPDATE "private_message" SET "delivered_at" = "createdAt";
+CREATE INDEX IF NOT EXISTS "idx_private_message_pending" ON "private_message" ("receiver_id", "delivered_at");`,
+	Down: `
+DROP INDEX IF EXISTS "idx_private_message_pending";
+ALTER TABLE "private_message" DROP COLUMN "delivered_at";`,
+}
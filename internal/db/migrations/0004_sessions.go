@@ -0,0 +1,25 @@
+package migrations
+
+// sessions keeps login sessions in the database so they survive a restart.
+// Only a hash of the session token is stored, never the token itself.
+var sessions = Migration{
+	Version: 4,
+	Name:    "sessions",
+	Up: `
+CREATE TABLE IF NOT EXISTS "session" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"token_hash"	TEXT NOT NULL UNIQUE,
+	"user_id"	INTEGER NOT NULL,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"expires_at"	DATETIME NOT NULL,
+	"last_seen"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"user_agent"	TEXT NOT NULL DEFAULT '',
+	"ip"	TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_session_user" ON "session" ("user_id");`,
+	Down: `
+DROP INDEX IF EXISTS "idx_session_user";
+DROP TABLE IF EXISTS "session";`,
+}
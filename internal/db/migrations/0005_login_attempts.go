@@ -0,0 +1,28 @@
+package migrations
+
+// loginAttempts records every login attempt, to throttle brute-force
+// attacks per account and per address and to audit failures
+var loginAttempts = Migration{
+	Version: 5,
+	Name:    "login_attempts",
+	Up: `
+CREATE TABLE IF NOT EXISTS "login_attempt" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"login"	TEXT NOT NULL,
+	"user_id"	INTEGER,
+	"ip"	TEXT NOT NULL,
+	"user_agent"	TEXT NOT NULL DEFAULT '',
+	"success"	INTEGER NOT NULL,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS "idx_login_attempt_user" ON "login_attempt" ("user_id", "created_at");
+CREATE INDEX IF NOT EXISTS "idx_login_attempt_login" ON "login_attempt" ("login", "created_at");
+CREATE INDEX IF NOT EXISTS "idx_login_attempt_ip" ON "login_attempt" ("ip", "created_at");`,
+	Down: `
+DROP INDEX IF EXISTS "idx_login_attempt_ip";
+DROP INDEX IF EXISTS "idx_login_attempt_login";
+DROP INDEX IF EXISTS "idx_login_attempt_user";
+DROP TABLE IF EXISTS "login_attempt";`,
+}
@@ -0,0 +1,21 @@
+package migrations
+
+// passwordReset holds the single-use links to choose a new password. Only a
+// hash of the token sent by email is stored.
+var passwordReset = Migration{
+	Version: 6,
+	Name:    "password_reset",
+	Up: `
+CREATE TABLE IF NOT EXISTS "password_reset" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"user_id"	INTEGER NOT NULL,
+	"token_hash"	TEXT NOT NULL UNIQUE,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"expires_at"	DATETIME NOT NULL,
+	"used_at"	DATETIME,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);`,
+	Down: `
+DROP TABLE IF EXISTS "password_reset";`,
+}
@@ -0,0 +1,27 @@
+package migrations
+
+// emailVerification records when a user proved they own their email
+// address, and holds the links sent to do it. Accounts that existed before
+// are trusted as verified.
+var emailVerification = Migration{
+	Version: 7,
+	Name:    "email_verification",
+	Up: `
+ALTER TABLE "user" ADD COLUMN "email_verified_at" DATETIME;
+UPDATE "user" SET "email_verified_at" = CURRENT_TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS "email_verification" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"user_id"	INTEGER NOT NULL,
+	"email"	TEXT NOT NULL,
+	"token_hash"	TEXT NOT NULL UNIQUE,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"expires_at"	DATETIME NOT NULL,
+	"used_at"	DATETIME,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);`,
+	Down: `
+DROP TABLE IF EXISTS "email_verification";
+ALTER TABLE "user" DROP COLUMN "email_verified_at";`,
+}
@@ -0,0 +1,31 @@
+package migrations
+
+// categories lets posts be filed under one or more categories. Posts
+// written before categories existed go to General.
+var categories = Migration{
+	Version: 8,
+	Name:    "categories",
+	Up: `
+CREATE TABLE IF NOT EXISTS "category" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"name"	TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("id" AUTOINCREMENT)
+);
+
+CREATE TABLE IF NOT EXISTS "post_category" (
+	"post_id"	INTEGER NOT NULL,
+	"category_id"	INTEGER NOT NULL,
+	PRIMARY KEY("post_id", "category_id"),
+	FOREIGN KEY (post_id) REFERENCES "post"(id) ON DELETE CASCADE,
+	FOREIGN KEY (category_id) REFERENCES "category"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_post_category_category ON post_category(category_id);
+
+INSERT INTO "category" (name) VALUES ('General'), ('Tech'), ('Gaming'), ('Music'), ('Off-topic');
+INSERT INTO "post_category" (post_id, category_id)
+	SELECT id, (SELECT id FROM "category" WHERE name = 'General') FROM "post";`,
+	Down: `
+DROP TABLE IF EXISTS "post_category";
+DROP TABLE IF EXISTS "category";`,
+}
@@ -0,0 +1,37 @@
+package migrations
+
+// editHistory keeps the earlier versions of posts and comments, one row per
+// edit holding the content as it was before it. The history outlives its
+// editors: edited_by is cleared when one of them is purged.
+var editHistory = Migration{
+	Version: 9,
+	Name:    "edit_history",
+	Up: `
+CREATE TABLE IF NOT EXISTS "post_revision" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"post_id"	INTEGER NOT NULL,
+	"title"	TEXT NOT NULL,
+	"body"	TEXT NOT NULL,
+	"edited_by"	INTEGER,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY (post_id) REFERENCES "post"(id) ON DELETE CASCADE,
+	FOREIGN KEY (edited_by) REFERENCES "user"(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_post_revision_post ON post_revision(post_id);
+
+CREATE TABLE IF NOT EXISTS "comment_revision" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"comment_id"	INTEGER NOT NULL,
+	"body"	TEXT NOT NULL,
+	"edited_by"	INTEGER,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY (comment_id) REFERENCES "comment"(id) ON DELETE CASCADE,
+	FOREIGN KEY (edited_by) REFERENCES "user"(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_comment_revision_comment ON comment_revision(comment_id);`,
+	Down: `
+DROP TABLE IF EXISTS "comment_revision";
+DROP TABLE IF EXISTS "post_revision";`,
+}
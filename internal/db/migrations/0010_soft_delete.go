@@ -0,0 +1,62 @@
+package migrations
+
+// softDelete turns deletions into tombstones: deleted rows keep their data
+// with the time they were deleted, so they can be restored until the
+// retention job purges them. deleted_by isn't a foreign key, the user who
+// deleted something may be purged before it. deleted_with_user marks what
+// was deleted along with its user's account, to be restored with it.
+//
+// The purge removes the notifications of purged users, so the notification
+// table is rebuilt for the databases where it still references a "users"
+// table that never existed, which makes any delete from it fail.
+var softDelete = Migration{
+	Version: 10,
+	Name:    "soft_delete",
+	Up: `
+ALTER TABLE "post" ADD COLUMN "deleted_at" DATETIME;
+ALTER TABLE "post" ADD COLUMN "deleted_by" INTEGER;
+ALTER TABLE "post" ADD COLUMN "deleted_with_user" INTEGER;
+ALTER TABLE "comment" ADD COLUMN "deleted_at" DATETIME;
+ALTER TABLE "comment" ADD COLUMN "deleted_by" INTEGER;
+ALTER TABLE "comment" ADD COLUMN "deleted_with_user" INTEGER;
+ALTER TABLE "private_message" ADD COLUMN "deleted_at" DATETIME;
+ALTER TABLE "private_message" ADD COLUMN "deleted_with_user" INTEGER;
+ALTER TABLE "user" ADD COLUMN "deleted_at" DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_post_deleted ON post(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_comment_deleted ON comment(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_private_message_deleted ON private_message(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_user_deleted ON "user"(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE notification_rebuilt (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	sender_id INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	content TEXT NOT NULL,
+	related_id INTEGER NOT NULL,
+	read BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES "user"(id),
+	FOREIGN KEY (sender_id) REFERENCES "user"(id)
+);
+INSERT INTO notification_rebuilt (id, user_id, sender_id, type, content, related_id, read, created_at)
+	SELECT id, user_id, sender_id, type, content, related_id, read, created_at FROM notification
+	WHERE user_id IN (SELECT id FROM "user") AND sender_id IN (SELECT id FROM "user");
+DROP TABLE notification;
+ALTER TABLE notification_rebuilt RENAME TO notification;`,
+	Down: `
+DROP INDEX IF EXISTS idx_user_deleted;
+DROP INDEX IF EXISTS idx_private_message_deleted;
+DROP INDEX IF EXISTS idx_comment_deleted;
+DROP INDEX IF EXISTS idx_post_deleted;
+ALTER TABLE "user" DROP COLUMN "deleted_at";
+ALTER TABLE "private_message" DROP COLUMN "deleted_with_user";
+ALTER TABLE "private_message" DROP COLUMN "deleted_at";
+ALTER TABLE "comment" DROP COLUMN "deleted_with_user";
+ALTER TABLE "comment" DROP COLUMN "deleted_by";
+ALTER TABLE "comment" DROP COLUMN "deleted_at";
+ALTER TABLE "post" DROP COLUMN "deleted_with_user";
+ALTER TABLE "post" DROP COLUMN "deleted_by";
+ALTER TABLE "post" DROP COLUMN "deleted_at";`,
+}
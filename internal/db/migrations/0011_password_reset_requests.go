@@ -0,0 +1,22 @@
+package migrations
+
+// passwordResetRequests records every password reset request, to limit how
+// many emails a login or an address can have sent
+var passwordResetRequests = Migration{
+	Version: 11,
+	Name:    "password_reset_requests",
+	Up: `
+CREATE TABLE IF NOT EXISTS "password_reset_request" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"login"	TEXT NOT NULL,
+	"ip"	TEXT NOT NULL,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("id" AUTOINCREMENT)
+);
+CREATE INDEX IF NOT EXISTS "idx_password_reset_request_login" ON "password_reset_request" ("login", "created_at");
+CREATE INDEX IF NOT EXISTS "idx_password_reset_request_ip" ON "password_reset_request" ("ip", "created_at");`,
+	Down: `
+DROP INDEX IF EXISTS "idx_password_reset_request_ip";
+DROP INDEX IF EXISTS "idx_password_reset_request_login";
+DROP TABLE IF EXISTS "password_reset_request";`,
+}
@@ -0,0 +1,23 @@
+package migrations
+
+// emailVerificationRequests records every verification email sent, to limit
+// how many an account can have sent, and how many an address can receive
+var emailVerificationRequests = Migration{
+	Version: 12,
+	Name:    "email_verification_requests",
+	Up: `
+CREATE TABLE IF NOT EXISTS "email_verification_request" (
+	"id"	INTEGER NOT NULL UNIQUE,
+	"user_id"	INTEGER NOT NULL,
+	"email"	TEXT NOT NULL,
+	"created_at"	DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("id" AUTOINCREMENT),
+	FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_email_verification_request_user" ON "email_verification_request" ("user_id", "created_at");
+CREATE INDEX IF NOT EXISTS "idx_email_verification_request_email" ON "email_verification_request" ("email", "created_at");`,
+	Down: `
+DROP INDEX IF EXISTS "idx_email_verification_request_email";
+DROP INDEX IF EXISTS "idx_email_verification_request_user";
+DROP TABLE IF EXISTS "email_verification_request";`,
+}
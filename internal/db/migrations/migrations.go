@@ -0,0 +1,175 @@
+// Package migrations keeps the database schema in step with the code.
+// Every change to the schema is a numbered Migration with the SQL to apply
+// it and the SQL to revert it; the versions already applied are recorded in
+// the schema_migrations table.
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Migration is one numbered, reversible schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State reports whether a migration has been applied to the database
+type State struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// all lists every known migration, in the order they must be applied
+var all = []Migration{
+	initialSchema,
+	postStatus,
+	privateMessageDelivery,
+	sessions,
+	loginAttempts,
+	passwordReset,
+	emailVerification,
+	categories,
+	editHistory,
+	softDelete,
+	passwordResetRequests,
+	emailVerificationRequests,
+}
+
+func createMigrationsTable(db *sql.DB) error {
+	createTableSQL := `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+	"version"	INTEGER NOT NULL UNIQUE,
+	"name"	TEXT NOT NULL,
+	"applied_at"	DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("version")
+)`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// applied returns the applied versions mapped to the time they were applied
+func applied(db *sql.DB) (map[int]string, error) {
+	if err := createMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning schema_migrations: %v", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
+
+// Up applies every pending migration in order and returns how many ran.
+// Each migration runs in its own transaction, so a failure leaves the
+// database at the last migration that succeeded.
+func Up(db *sql.DB) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range sorted() {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		if err := run(db, m, m.Up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Down reverts the last `steps` applied migrations, newest first
+func Down(db *sql.DB, steps int) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+
+	migrations := sorted()
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		if err := run(db, m, m.Down, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Status lists every known migration along with whether it has been applied
+func Status(db *sql.DB) ([]State, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []State
+	for _, m := range sorted() {
+		appliedAt, ok := done[m.Version]
+		states = append(states, State{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return states, nil
+}
+
+// run executes a migration script and its bookkeeping statement atomically
+func run(db *sql.DB, m Migration, script, record string, args ...any) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+	}
+
+	if _, err := tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error recording migration %04d_%s: %v", m.Version, m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing migration %04d_%s: %v", m.Version, m.Name, err)
+	}
+
+	return nil
+}
+
+func sorted() []Migration {
+	migrations := make([]Migration, len(all))
+	copy(migrations, all)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
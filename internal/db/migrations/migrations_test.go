@@ -0,0 +1,132 @@
+package migrations_test
+
+import (
+	"database/sql"
+	"db"
+	"db/migrations"
+	"strings"
+	"testing"
+)
+
+// openMemory returns the connection pool of an empty in-memory database
+func openMemory(t *testing.T) *sql.DB {
+	t.Helper()
+
+	s, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s.DB
+}
+
+// schema lists the tables, indexes and triggers of the database with their SQL
+func schema(t *testing.T, conn *sql.DB) string {
+	t.Helper()
+
+	rows, err := conn.Query(`SELECT type, name, COALESCE(sql, '') FROM sqlite_master
+                             WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var objects []string
+	for rows.Next() {
+		var kind, name, sql string
+		if err := rows.Scan(&kind, &name, &sql); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, kind+" "+name+": "+sql)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(objects, "\n")
+}
+
+func TestUpAppliesEveryMigrationOnce(t *testing.T) {
+	conn := openMemory(t)
+
+	states, err := migrations.Status(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := migrations.Up(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(states) {
+		t.Errorf("Up applied %d migration(s), want %d", count, len(states))
+	}
+
+	states, err = migrations.Status(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if !state.Applied {
+			t.Errorf("migration %04d_%s not applied", state.Version, state.Name)
+		}
+	}
+
+	if count, err := migrations.Up(conn); err != nil || count != 0 {
+		t.Errorf("second Up = %d, %v, want nothing to apply", count, err)
+	}
+}
+
+func TestDownThenUpRebuildsTheSameSchema(t *testing.T) {
+	conn := openMemory(t)
+	total, err := migrations.Up(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := schema(t, conn)
+
+	// Revert more and more of the migrations, up to all of them, so every
+	// Down script is checked against its Up
+	for steps := 1; steps <= total; steps++ {
+		reverted, err := migrations.Down(conn, steps)
+		if err != nil {
+			t.Fatalf("Down(%d): %v", steps, err)
+		}
+		if reverted != steps {
+			t.Fatalf("Down(%d) reverted %d migration(s)", steps, reverted)
+		}
+		if _, err := migrations.Up(conn); err != nil {
+			t.Fatalf("Up after Down(%d): %v", steps, err)
+		}
+		if got := schema(t, conn); got != want {
+			t.Fatalf("schema after Down(%d) and Up differs:\n%s\nwant:\n%s", steps, got, want)
+		}
+	}
+}
+
+func TestDownEverythingLeavesOnlyTheBookkeeping(t *testing.T) {
+	conn := openMemory(t)
+	total, err := migrations.Up(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Down(conn, total); err != nil {
+		t.Fatal(err)
+	}
+
+	var tables []string
+	rows, err := conn.Query(`SELECT name FROM sqlite_master
+                             WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	if len(tables) != 1 || tables[0] != "schema_migrations" {
+		t.Errorf("tables left after reverting everything: %v", tables)
+	}
+}
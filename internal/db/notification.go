@@ -7,28 +7,8 @@ import (
 	"time"
 )
 
-func createNotificationsTable(db *sql.DB) {
-	sql := `
-	CREATE TABLE IF NOT EXISTS notification (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		sender_id INTEGER NOT NULL,
-		type TEXT NOT NULL,
-		content TEXT NOT NULL,
-		related_id INTEGER NOT NULL,
-		read BOOLEAN DEFAULT FALSE,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id),
-		FOREIGN KEY (sender_id) REFERENCES users(id)
-	);`
-	executeSQL(db, sql)
-}
-
-func NotificationInsert(userID int, senderID int, notificationType string, content string, relatedID int) (int, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) NotificationInsert(userID int, senderID int, notificationType string, content string, relatedID int) (int, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return 0, fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -55,11 +35,8 @@ func NotificationInsert(userID int, senderID int, notificationType string, conte
 }
 
 // Read - Get notification by ID
-func NotificationSelectByID(notificationID int) (*models.Notification, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) NotificationSelectByID(notificationID int) (*models.Notification, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -105,11 +82,8 @@ func NotificationSelectByID(notificationID int) (*models.Notification, error) {
 }
 
 // Read - Get notifications by user ID
-func NotificationSelectByUserID(userID int) ([]*models.Notification, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) NotificationSelectByUserID(userID int) ([]*models.Notification, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -167,11 +141,8 @@ func NotificationSelectByUserID(userID int) ([]*models.Notification, error) {
 }
 
 // Update - Mark notification as read
-func NotificationUpdateReadStatus(notificationID int, read bool) error {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) NotificationUpdateReadStatus(notificationID int, read bool) error {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -197,11 +168,8 @@ func NotificationUpdateReadStatus(notificationID int, read bool) error {
 }
 
 // Delete - Delete notification
-func NotificationDelete(notificationID int) error {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) NotificationDelete(notificationID int) error {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Create - Record a password reset request, unless the login or the address
+// already made as many as allowed since the given time. The check and the
+// insert are one statement, so concurrent requests can't both slip under the
+// limit. Returns false when the request was refused.
+func (s *Store) PasswordResetRequestInsert(login, ip string, since time.Time, maxPerLogin, maxPerIP int) (bool, error) {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	cutoff := since.UTC().Format("2006-01-02 15:04:05")
+	login = strings.ToLower(login)
+
+	insertSQL := `INSERT INTO password_reset_request (login, ip, created_at)
+                  SELECT ?, ?, ?
+                  WHERE (SELECT COUNT(*) FROM password_reset_request WHERE login = ? AND created_at > ?) < ?
+                  AND (SELECT COUNT(*) FROM password_reset_request WHERE ip = ? AND created_at > ?) < ?`
+	result, err := s.DB.Exec(insertSQL, login, ip, now,
+		login, cutoff, maxPerLogin, ip, cutoff, maxPerIP)
+	if err != nil {
+		return false, fmt.Errorf("error inserting password reset request: %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error reading affected rows: %v", err)
+	}
+	return inserted == 1, nil
+}
+
+// Create - Record a password reset token for a user. Links sent before
+// stop working, only the latest one can be used.
+func (s *Store) PasswordResetInsert(userID int, tokenHash string, expiresAt time.Time) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	if _, err = tx.Exec(`DELETE FROM password_reset WHERE user_id = ? AND used_at IS NULL`, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting previous tokens: %v", err)
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	insertSQL := `INSERT INTO password_reset (user_id, token_hash, created_at, expires_at)
+                  VALUES (?, ?, ?, ?)`
+	_, err = tx.Exec(insertSQL, userID, tokenHash, now, expiresAt.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error inserting token: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Update - Use up a password reset token and return the user it was
+// issued to. Fails if the token is unknown, expired or already used.
+func (s *Store) PasswordResetConsume(tokenHash string) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	var id, userID int
+	query := `SELECT id, user_id FROM password_reset
+              WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`
+	if err = tx.QueryRow(query, tokenHash, now).Scan(&id, &userID); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("invalid or expired token")
+		}
+		return 0, fmt.Errorf("error executing query: %v", err)
+	}
+
+	if _, err = tx.Exec(`UPDATE password_reset SET used_at = ? WHERE id = ?`, now, id); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("error executing statement: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return userID, nil
+}
@@ -7,33 +7,18 @@ import (
 	"time"
 )
 
-func createPostsTable(db *sql.DB) {
-	createTableSQL := `CREATE TABLE IF NOT EXISTS "post" (
-    "id"    INTEGER NOT NULL UNIQUE,
-    "user_id"    TEXT NOT NULL,
-	"user"	TEXT NOT NULL,
-    "title"    TEXT NOT NULL,
-    "body"    TEXT NOT NULL,
-    "createdAt"    DATETIME DEFAULT CURRENT_TIMESTAMP,
-    "updatedAt"    DATETIME DEFAULT CURRENT_TIMESTAMP,
-    PRIMARY KEY("id" AUTOINCREMENT),
-    FOREIGN KEY("user_id") REFERENCES "User"("id")
-)`
-	executeSQL(db, createTableSQL)
-}
-
-// Create - Insert a new post
-func PostInsert(userID int, uuid, title, body string) (*models.Post, error) {
-	db := SetupDatabase()
-	defer db.Close()
+// Create - Insert a new post in the given categories
+func (s *Store) PostInsert(userID int, title, body string, categoryIDs []int) (*models.Post, error) {
+	// Resolve the nickname before opening the transaction so the lookup
+	// doesn't wait on the connection the transaction is holding
+	user := s.UserNicknameWithID(userID)
 
-	tx, err := db.Begin()
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	user := UserNicknameWithUUID(uuid)
-	now := time.Now().Format("2006-01-02 15:04:05") // Fix date format
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
 
 	// Match the column names in your 'post' table
 	insertSQL := `INSERT INTO post (user_id, user, title, body, createdAt) 
@@ -50,42 +35,69 @@ func PostInsert(userID int, uuid, title, body string) (*models.Post, error) {
 		return nil, fmt.Errorf("error getting last insert ID: %v", err)
 	}
 
+	for _, categoryID := range categoryIDs {
+		if _, err = tx.Exec(`INSERT OR IGNORE INTO post_category (post_id, category_id) VALUES (?, ?)`,
+			postID, categoryID); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("error filing post: %v", err)
+		}
+	}
+	var names sql.NullString
+	err = tx.QueryRow(`SELECT `+postCategoriesColumn+` FROM post p WHERE p.id = ?`, postID).Scan(&names)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("error reading categories: %v", err)
+	}
+	categories := splitCategories(names)
+
 	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("error committing transaction: %v", err)
 	}
 
 	createdTime, _ := time.Parse("2006-01-02 15:04:05", now)
 	post := &models.Post{
-		ID:        int(postID),
-		UserID:    userID,
-		Username:  user,
-		Title:     title,
-		Body:      body,
-		CreatedAt: createdTime,
+		ID:         int(postID),
+		UserID:     userID,
+		Username:   user,
+		Title:      title,
+		Body:       body,
+		Status:     "published",
+		Categories: categories,
+		CreatedAt:  createdTime,
 	}
 
 	return post, nil
 }
 
 // Read - Get post by ID
-func PostSelectByID(postID int) (*models.Post, error) {
-	db := SetupDatabase()
-	defer db.Close()
+func (s *Store) PostSelectByID(postID int) (*models.Post, error) {
+	return s.postSelectByID(postID, false)
+}
+
+// Read - Get a deleted post by ID, e.g. to restore it. Posts deleted with
+// their author's account only come back with it.
+func (s *Store) PostSelectDeletedByID(postID int) (*models.Post, error) {
+	return s.postSelectByID(postID, true)
+}
 
-	tx, err := db.Begin()
+func (s *Store) postSelectByID(postID int, deleted bool) (*models.Post, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	query := `SELECT id, user_id, title, body, createdAt, updatedAt
-             FROM post WHERE id = ?`
+	query := `SELECT p.id, p.user_id, p.user, p.title, p.body, p.status, p.createdAt, p.updatedAt, ` + postCategoriesColumn + `,
+                 ` + postCommentCountColumn + `, (SELECT COUNT(*) FROM post_revision r WHERE r.post_id = p.id),
+                 COALESCE(p.deleted_by, 0)
+             FROM post p WHERE p.id = ? AND (p.deleted_at IS NOT NULL) = ? AND p.deleted_with_user IS NULL`
 
 	var post models.Post
-	var createdAtStr, updatedAtStr string
+	var createdAt, updatedAt sql.NullTime // DATETIME columns come back as times
+	var categories sql.NullString
 
-	err = tx.QueryRow(query, postID).Scan(
-		&post.ID, &post.UserID, &post.Title, &post.Body,
-		&createdAtStr, &updatedAtStr,
+	err = tx.QueryRow(query, postID, deleted).Scan(
+		&post.ID, &post.UserID, &post.Username, &post.Title, &post.Body, &post.Status,
+		&createdAt, &updatedAt, &categories, &post.CommentCount, &post.Revisions, &post.DeletedBy,
 	)
 
 	if err != nil {
@@ -96,9 +108,9 @@ func PostSelectByID(postID int) (*models.Post, error) {
 		return nil, fmt.Errorf("error executing query: %v", err)
 	}
 
-	// Parse time strings
-	post.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAtStr)
-	post.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAtStr)
+	post.CreatedAt = createdAt.Time
+	post.UpdatedAt = updatedAt.Time
+	post.Categories = splitCategories(categories)
 
 	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("error committing transaction: %v", err)
@@ -108,16 +120,13 @@ func PostSelectByID(postID int) (*models.Post, error) {
 }
 
 // Read - Get post title by ID (your existing function)
-func PostTitleSelectById(postID int) (string, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) PostTitleSelectById(postID int) (string, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return "", fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	query := `SELECT p.title FROM post p WHERE p.id = ?`
+	query := `SELECT p.title FROM post p WHERE p.id = ? AND p.deleted_at IS NULL`
 
 	var title string
 	err = tx.QueryRow(query, postID).Scan(&title)
@@ -136,65 +145,15 @@ func PostTitleSelectById(postID int) (string, error) {
 	return title, nil
 }
 
-// Read - Get all posts
-func PostSelectAll() ([]models.Post, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	query := `SELECT id, user_id, user, title, body, createdAt FROM post ORDER BY createdAt DESC`
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("error querying posts: %v", err)
-	}
-	defer rows.Close()
-
-	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		var createdAtStr string // Declare as string first
-
-		// Modify the Scan to use a string
-		err = rows.Scan(
-			&post.ID,
-			&post.UserID,
-			&post.Username,
-			&post.Title,
-			&post.Body,
-			&createdAtStr,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning post: %v", err)
-		}
-
-		// Parse the string to time.Time using correct format
-		post.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAtStr)
-		if err != nil {
-			// If parsing fails, use current time
-			post.CreatedAt = time.Now()
-		}
-
-		posts = append(posts, post)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %v", err)
-	}
-
-	return posts, nil
-}
-
 // Read - Get posts by user ID
-func PostSelectByUserID(userID int) ([]*models.Post, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) PostSelectByUserID(userID int) ([]*models.Post, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
 	query := `SELECT id, user_id, title, body, createdAt, updatedAt
-             FROM post WHERE user_id = ?`
+             FROM post WHERE user_id = ? AND deleted_at IS NULL`
 
 	rows, err := tx.Query(query, userID)
 	if err != nil {
@@ -233,53 +192,55 @@ func PostSelectByUserID(userID int) ([]*models.Post, error) {
 	return posts, nil
 }
 
-// Update - Update post content
-func PostUpdateContent(id int, title, body string) error {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+// Update - Update post content, keeping the previous version in its history.
+// Returns false, leaving the post alone, when nothing changes.
+func (s *Store) PostUpdateContent(id, editorID int, title, body string) (bool, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return false, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	now := time.Now().Format("2006-01-02 15:04:05")
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	changed, err := postRevisionInsert(tx, id, editorID, title, body, now)
+	if err != nil || !changed {
+		tx.Rollback()
+		return false, err
+	}
 
 	updateSQL := `UPDATE post SET title=?, body=?, updatedAt=? WHERE id=?`
 	_, err = tx.Exec(updateSQL, title, body, now, id)
 
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error executing statement: %v", err)
+		return false, fmt.Errorf("error executing statement: %v", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return false, fmt.Errorf("error committing transaction: %v", err)
 	}
 
-	return nil
+	return true, nil
 }
 
-// Delete - Delete post
-func PostDelete(postID int) error {
-	db := SetupDatabase()
-	defer db.Close()
+// Delete - Mark a post as deleted. Its comments stay, hidden with it, until
+// the post is restored or purged.
+func (s *Store) PostDelete(postID, deletedBy int) error {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
 
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+	updateSQL := `UPDATE post SET deleted_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`
+	if _, err := s.DB.Exec(updateSQL, now, deletedBy, postID); err != nil {
+		return fmt.Errorf("error deleting post: %v", err)
 	}
 
-	deleteSQL := `DELETE FROM post WHERE id=?`
-	_, err = tx.Exec(deleteSQL, postID)
-
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("error executing statement: %v", err)
-	}
+	return nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+// Update - Bring back a deleted post, with its comments
+func (s *Store) PostRestore(postID int) error {
+	updateSQL := `UPDATE post SET deleted_at = NULL, deleted_by = NULL WHERE id = ?`
+	if _, err := s.DB.Exec(updateSQL, postID); err != nil {
+		return fmt.Errorf("error restoring post: %v", err)
 	}
 
 	return nil
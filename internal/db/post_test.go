@@ -0,0 +1,80 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPostSelectByIDCountsLiveComments(t *testing.T) {
+	s := testStore(t)
+	author := testUser(t, s, "author")
+	postID := testPost(t, s, author, "post")
+
+	for _, body := range []string{"first", "second"} {
+		if _, err := s.CommentInsert(author, postID, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleted, err := s.CommentInsert(author, postID, "third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CommentDelete(deleted.ID, author); err != nil {
+		t.Fatal(err)
+	}
+
+	post, err := s.PostSelectByID(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.CommentCount != 2 {
+		t.Errorf("CommentCount = %d, want 2", post.CommentCount)
+	}
+}
+
+func TestPostUpdateContentSkipsEditsChangingNothing(t *testing.T) {
+	s := testStore(t)
+	author := testUser(t, s, "author")
+	postID := testPost(t, s, author, "title")
+
+	changed, err := s.PostUpdateContent(postID, author, "title", "body")
+	if err != nil || changed {
+		t.Fatalf("same content: changed = %v, %v", changed, err)
+	}
+	changed, err = s.PostUpdateContent(postID, author, "title", "new body")
+	if err != nil || !changed {
+		t.Fatalf("new body: changed = %v, %v", changed, err)
+	}
+
+	revisions, err := s.PostRevisions(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || revisions[0].Body != "body" {
+		t.Errorf("got revisions %+v, want the original body only", revisions)
+	}
+}
+
+func TestPostsAndCommentsAreStampedInUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	s := testStore(t)
+	author := testUser(t, s, "author")
+	postID := testPost(t, s, author, "post")
+	if _, err := s.CommentInsert(author, postID, "comment"); err != nil {
+		t.Fatal(err)
+	}
+
+	var postDrift, commentDrift float64
+	err := s.DB.QueryRow(`SELECT ABS(strftime('%s', p.createdAt) - strftime('%s', 'now')),
+	                             ABS(strftime('%s', c.createdAt) - strftime('%s', 'now'))
+	                      FROM post p JOIN comment c ON c.post_id = p.id WHERE p.id = ?`, postID).Scan(&postDrift, &commentDrift)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if postDrift > 60 || commentDrift > 60 {
+		t.Errorf("creation times are %vs and %vs away from UTC now", postDrift, commentDrift)
+	}
+}
@@ -2,107 +2,13 @@ package db
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"models"
-
-	"github.com/gorilla/websocket"
+	"time"
 )
 
-var clients = models.GetClientMap()
-var mu = models.GetMux()
-
-func createPrivateMessageTable(db *sql.DB) {
-	// A better schema for chat messages
-	createTableSQL := `CREATE TABLE IF NOT EXISTS "private_message" (
-	"id"	INTEGER NOT NULL UNIQUE,
-	"sender_id"	INTEGER NOT NULL,
-	"receiver_id"	INTEGER NOT NULL,
-	"message"	TEXT NOT NULL,
-	"createdAt"	NUMERIC DEFAULT CURRENT_TIMESTAMP,
-	"read"	INTEGER DEFAULT 0,
-	PRIMARY KEY("id" AUTOINCREMENT),
-	FOREIGN KEY("sender_id") REFERENCES "User"("id"),
-	FOREIGN KEY("receiver_id") REFERENCES "User"("id")
-)`
-
-	executeSQL(db, createTableSQL)
-}
-
-func SendPrivateMessage(msg models.PrivateMessage) {
-	// Ensure both sender and receiver are set
-	if msg.Sender == "" || msg.Receiver == "" {
-		fmt.Println("Error: Sender or receiver not specified")
-		return
-	}
-
-	// Check if the receiver exists (is connected)
-	mu.Lock()
-	receiverConn, receiverExists := clients[msg.Receiver]
-	senderConn, senderExists := clients[msg.Sender]
-	mu.Unlock()
-
-	// Create a response message
-	response := models.PrivateMessage{
-		Type:     "private_message",
-		Sender:   msg.Sender,
-		Receiver: msg.Receiver,
-		Message:  msg.Message,
-	}
-
-	// Convert response to JSON
-	jsonResponse, err := json.Marshal(response)
-	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return
-	}
-
-	// Send message to receiver if they're connected
-	if receiverExists {
-		err := receiverConn.WriteMessage(websocket.TextMessage, jsonResponse)
-		if err != nil {
-			fmt.Println("Error sending message to receiver:", err)
-		}
-	} else {
-		// Store the message in the database for offline delivery
-		// db.StoreOfflineMessage(msg.Sender, msg.Receiver, msg.Message)
-
-		// Notify sender that receiver is offline
-		if senderExists {
-			notifyMsg := models.PrivateMessage{
-				Type:     "system_notification",
-				Sender:   "system",
-				Receiver: msg.Sender,
-				Message:  msg.Receiver + " is currently offline. Message will be delivered when they connect.",
-			}
-
-			notifyJson, _ := json.Marshal(notifyMsg)
-			senderConn.WriteMessage(websocket.TextMessage, notifyJson)
-		}
-	}
-
-	// Also send a copy/confirmation to the sender
-	if senderExists {
-		confirmMsg := models.PrivateMessage{
-			Type:     "message_sent",
-			Sender:   msg.Sender,
-			Receiver: msg.Receiver,
-			Message:  msg.Message,
-		}
-
-		confirmJson, _ := json.Marshal(confirmMsg)
-		senderConn.WriteMessage(websocket.TextMessage, confirmJson)
-	}
-
-	// Log the message
-	fmt.Printf("Private message from %s to %s: %s\n", msg.Sender, msg.Receiver, msg.Message)
-}
-
-func PrivateMessageInsert(senderID, receiverID int, message string) (int, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) PrivateMessageInsert(senderID, receiverID int, message string) (int, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return 0, fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -129,17 +35,14 @@ func PrivateMessageInsert(senderID, receiverID int, message string) (int, error)
 }
 
 // Read - Get a message by ID
-func PrivateMessageSelectByID(messageID int) (*models.PrivateMessage, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) PrivateMessageSelectByID(messageID int) (*models.PrivateMessage, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
 	query := `SELECT id, sender_id, receiver_id, message, createdAt, read
-              FROM private_message WHERE id = ?`
+              FROM private_message WHERE id = ? AND deleted_at IS NULL`
 
 	var message models.PrivateMessage
 	var createdAtStr string
@@ -170,18 +73,15 @@ func PrivateMessageSelectByID(messageID int) (*models.PrivateMessage, error) {
 }
 
 // Read - Get all messages for a user (both sent and received)
-func PrivateMessageSelectByUserID(userID int) ([]*models.PrivateMessage, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) PrivateMessageSelectByUserID(userID int) ([]*models.PrivateMessage, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
 	query := `SELECT id, sender_id, receiver_id, message, createdAt, read
               FROM private_message 
-              WHERE sender_id = ? OR receiver_id = ?
+              WHERE (sender_id = ? OR receiver_id = ?) AND deleted_at IS NULL
               ORDER BY createdAt DESC`
 
 	rows, err := tx.Query(query, userID, userID)
@@ -223,11 +123,8 @@ func PrivateMessageSelectByUserID(userID int) ([]*models.PrivateMessage, error)
 }
 
 // Update - Mark message as read
-func PrivateMessageUpdateReadStatus(messageID int, read bool) error {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) PrivateMessageUpdateReadStatus(messageID int, read bool) error {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -252,22 +149,95 @@ func PrivateMessageUpdateReadStatus(messageID int, read bool) error {
 	return nil
 }
 
-// Delete - Delete message
-func PrivateMessageDelete(messageID int) error {
-	db := SetupDatabase()
-	defer db.Close()
+// Delete - Mark a message as deleted. Only its sender can, senderID guards
+// that. Returns the receiver, or 0 when there was no such message to delete.
+func (s *Store) PrivateMessageDelete(messageID, senderID int) (int, error) {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	updateSQL := `UPDATE private_message SET deleted_at = ?
+                  WHERE id = ? AND sender_id = ? AND deleted_at IS NULL RETURNING receiver_id, message`
+	receiverID, _, err := s.privateMessageSetDeleted(updateSQL, now, messageID, senderID)
+	return receiverID, err
+}
 
-	tx, err := db.Begin()
+// Update - Bring back a message its sender deleted. Returns the receiver and
+// the message, or 0 when there was no such message to restore.
+func (s *Store) PrivateMessageRestore(messageID, senderID int) (int, string, error) {
+	updateSQL := `UPDATE private_message SET deleted_at = NULL
+                  WHERE id = ? AND sender_id = ? AND deleted_at IS NOT NULL AND deleted_with_user IS NULL
+                  RETURNING receiver_id, message`
+	return s.privateMessageSetDeleted(updateSQL, messageID, senderID)
+}
+
+func (s *Store) privateMessageSetDeleted(updateSQL string, args ...interface{}) (int, string, error) {
+	var receiverID int
+	var message string
+	err := s.DB.QueryRow(updateSQL, args...).Scan(&receiverID, &message)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return 0, "", fmt.Errorf("error updating message: %v", err)
 	}
 
-	deleteSQL := `DELETE FROM private_message WHERE id = ?`
-	_, err = tx.Exec(deleteSQL, messageID)
+	return receiverID, message, nil
+}
+
+// Read - Get the messages a user hasn't received yet, oldest first
+func (s *Store) PrivateMessageSelectPending(receiverID int) ([]models.ChatHistoryMessage, error) {
+	query := `SELECT pm.id, pm.sender_id, u.nickName, pm.message, pm.createdAt, pm.read
+              FROM private_message pm
+              JOIN user u ON pm.sender_id = u.id
+              WHERE pm.receiver_id = ? AND pm.delivered_at IS NULL AND pm.deleted_at IS NULL
+              ORDER BY pm.id ASC`
 
+	rows, err := s.DB.Query(query, receiverID)
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("error executing statement: %v", err)
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	messages := []models.ChatHistoryMessage{}
+	for rows.Next() {
+		message := models.ChatHistoryMessage{Type: "chat_history_message"}
+		var read int
+
+		if err := rows.Scan(&message.ID, &message.SenderID, &message.Sender,
+			&message.Message, &message.Timestamp, &read); err != nil {
+			return nil, fmt.Errorf("error scanning message: %v", err)
+		}
+		message.Read = read != 0
+
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating messages: %v", err)
+	}
+
+	return messages, nil
+}
+
+// Update - Mark messages as delivered once the receiver acknowledged them.
+// Only messages addressed to receiverID are touched.
+func (s *Store) PrivateMessageMarkDelivered(receiverID int, messageIDs []int) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	updateSQL := `UPDATE private_message SET delivered_at = ?
+                  WHERE id = ? AND receiver_id = ? AND delivered_at IS NULL`
+	for _, id := range messageIDs {
+		if _, err := tx.Exec(updateSQL, now, id, receiverID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error executing statement: %v", err)
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -276,3 +246,24 @@ func PrivateMessageDelete(messageID int) error {
 
 	return nil
 }
+
+// Update - Mark every message a sender sent to a reader as read, up to and
+// including upToID. Reading a message also counts as receiving it.
+// Returns how many messages changed state.
+func (s *Store) PrivateMessageMarkRead(readerID, senderID, upToID int) (int64, error) {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	updateSQL := `UPDATE private_message SET read = 1, delivered_at = COALESCE(delivered_at, ?)
+                  WHERE receiver_id = ? AND sender_id = ? AND id <= ? AND read = 0`
+	result, err := s.DB.Exec(updateSQL, now, readerID, senderID, upToID)
+	if err != nil {
+		return 0, fmt.Errorf("error executing statement: %v", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error counting updated messages: %v", err)
+	}
+
+	return count, nil
+}
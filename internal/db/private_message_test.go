@@ -0,0 +1,96 @@
+package db
+
+import "testing"
+
+// pendingIDs lists the IDs of the messages a user hasn't received yet
+func pendingIDs(t *testing.T, s *Store, receiverID int) []int {
+	t.Helper()
+
+	messages, err := s.PrivateMessageSelectPending(receiverID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestPendingMessagesWaitForTheReceiversAck(t *testing.T) {
+	s := testStore(t)
+	alice := testUser(t, s, "alice")
+	bob := testUser(t, s, "bob")
+	toBob := testMessages(t, s, alice, bob, 3)
+	toAlice := testMessages(t, s, bob, alice, 1)
+	if _, err := s.PrivateMessageDelete(toBob[2], alice); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleted messages aren't delivered at all
+	if ids := pendingIDs(t, s, bob); !equalIDs(ids, toBob[:2]) {
+		t.Fatalf("bob's pending %v, want %v", ids, toBob[:2])
+	}
+
+	// Acks only count for messages addressed to whoever sends them
+	if err := s.PrivateMessageMarkDelivered(alice, toBob); err != nil {
+		t.Fatal(err)
+	}
+	if ids := pendingIDs(t, s, bob); !equalIDs(ids, toBob[:2]) {
+		t.Errorf("bob's pending after alice's ack %v, want %v", ids, toBob[:2])
+	}
+
+	if err := s.PrivateMessageMarkDelivered(bob, []int{toBob[0], toAlice[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := pendingIDs(t, s, bob); !equalIDs(ids, toBob[1:2]) {
+		t.Errorf("bob's pending after his ack %v, want %v", ids, toBob[1:2])
+	}
+	if ids := pendingIDs(t, s, alice); !equalIDs(ids, toAlice) {
+		t.Errorf("alice's pending after bob's ack %v, want %v", ids, toAlice)
+	}
+}
+
+func TestMarkReadStopsAtTheGivenMessage(t *testing.T) {
+	s := testStore(t)
+	alice := testUser(t, s, "alice")
+	bob := testUser(t, s, "bob")
+	carol := testUser(t, s, "carol")
+	toBob := testMessages(t, s, alice, bob, 3)
+	fromCarol := testMessages(t, s, carol, bob, 1)
+	toAlice := testMessages(t, s, bob, alice, 1)
+
+	count, err := s.PrivateMessageMarkRead(bob, alice, toBob[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d messages read, want 2", count)
+	}
+	// Reading a message also received it
+	if ids := pendingIDs(t, s, bob); !equalIDs(ids, []int{toBob[2], fromCarol[0]}) {
+		t.Errorf("bob's pending %v, want %v", ids, []int{toBob[2], fromCarol[0]})
+	}
+
+	history, err := s.ChatHistory(bob, alice, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range history.Messages {
+		want := m.ID == toBob[0] || m.ID == toBob[1]
+		if m.Read != want {
+			t.Errorf("message %d: read %v, want %v", m.ID, m.Read, want)
+		}
+	}
+
+	// Only the reader's own messages from that sender, and only once
+	if count, err = s.PrivateMessageMarkRead(alice, bob, toBob[2]); err != nil || count != 0 {
+		t.Errorf("alice marking her own messages read: %d, %v", count, err)
+	}
+	if count, err = s.PrivateMessageMarkRead(bob, alice, toBob[1]); err != nil || count != 0 {
+		t.Errorf("reading the same messages again: %d, %v", count, err)
+	}
+	if count, err = s.PrivateMessageMarkRead(bob, alice, toAlice[0]); err != nil || count != 1 {
+		t.Errorf("reading the rest: %d, %v", count, err)
+	}
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeletedPlaceholder stands in for deleted comments and messages, so threads
+// and conversations keep their shape
+const DeletedPlaceholder = "[deleted]"
+
+// Delete - Remove for good everything deleted before the given time. A purged
+// user takes all their remaining posts, comments, messages and notifications
+// along, and a purged post its comments. Returns the number of rows removed.
+func (s *Store) PurgeDeleted(before time.Time) (int64, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	cutoff := before.UTC().Format("2006-01-02 15:04:05")
+	purgedUsers := `(SELECT id FROM user WHERE deleted_at < ?)`
+	purgedPosts := `(SELECT id FROM post WHERE deleted_at < ? OR user_id IN ` + purgedUsers + `)`
+
+	// Children before parents, the foreign keys don't cascade
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`DELETE FROM comment WHERE deleted_at < ? OR user_id IN ` + purgedUsers + ` OR post_id IN ` + purgedPosts,
+			[]interface{}{cutoff, cutoff, cutoff, cutoff}},
+		{`DELETE FROM post WHERE deleted_at < ? OR user_id IN ` + purgedUsers,
+			[]interface{}{cutoff, cutoff}},
+		{`DELETE FROM private_message WHERE deleted_at < ? OR sender_id IN ` + purgedUsers + ` OR receiver_id IN ` + purgedUsers,
+			[]interface{}{cutoff, cutoff, cutoff}},
+		{`DELETE FROM notification WHERE user_id IN ` + purgedUsers + ` OR sender_id IN ` + purgedUsers,
+			[]interface{}{cutoff, cutoff}},
+		{`DELETE FROM user WHERE deleted_at < ?`,
+			[]interface{}{cutoff}},
+	}
+
+	var purged int64
+	for _, statement := range statements {
+		result, err := tx.Exec(statement.query, statement.args...)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("error purging deleted rows: %v", err)
+		}
+		count, _ := result.RowsAffected()
+		purged += count
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return purged, nil
+}
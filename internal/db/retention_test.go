@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeKeepsTheHistoryEditedByAPurgedUser(t *testing.T) {
+	s := testStore(t)
+	moderator := testUser(t, s, "moderator")
+	author := testUser(t, s, "author")
+	postID := testPost(t, s, author, "original")
+
+	if _, err := s.PostUpdateContent(postID, moderator, "edited", "body"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UserDelete(moderator, author); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PurgeDeleted(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := s.PostRevisions(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("got %d revisions, want 1", len(revisions))
+	}
+	if revisions[0].Title != "original" || revisions[0].EditedBy != DeletedPlaceholder {
+		t.Errorf("got revision %q edited by %q", revisions[0].Title, revisions[0].EditedBy)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"models"
+)
+
+// Editor of a revision, shown as a placeholder once they are deleted
+const revisionEditor = `COALESCE(u.nickName, '` + DeletedPlaceholder + `')`
+
+// Read - Get the earlier versions of a post, latest first
+func (s *Store) PostRevisions(postID int) ([]models.Revision, error) {
+	query := `SELECT r.id, r.title, r.body, ` + revisionEditor + `, r.created_at
+             FROM post_revision r LEFT JOIN user u ON u.id = r.edited_by AND u.deleted_at IS NULL
+             WHERE r.post_id = ? ORDER BY r.id DESC`
+	return s.revisions(query, postID)
+}
+
+// Read - Get the earlier versions of a comment, latest first
+func (s *Store) CommentRevisions(commentID int) ([]models.Revision, error) {
+	query := `SELECT r.id, '', r.body, ` + revisionEditor + `, r.created_at
+             FROM comment_revision r LEFT JOIN user u ON u.id = r.edited_by AND u.deleted_at IS NULL
+             WHERE r.comment_id = ? ORDER BY r.id DESC`
+	return s.revisions(query, commentID)
+}
+
+func (s *Store) revisions(query string, id int) ([]models.Revision, error) {
+	rows, err := s.DB.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying revisions: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		var revision models.Revision
+		var editedAt sql.NullTime
+		if err := rows.Scan(&revision.ID, &revision.Title, &revision.Body, &revision.EditedBy, &editedAt); err != nil {
+			return nil, fmt.Errorf("error scanning revision: %v", err)
+		}
+		revision.EditedAt = editedAt.Time
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return revisions, nil
+}
+
+// Create - Keep the current version of a post as a revision, unless the new
+// title and body are the same. Call it in the transaction that edits the
+// post; it reports whether there is anything to edit.
+func postRevisionInsert(tx *sql.Tx, postID, editorID int, title, body, now string) (bool, error) {
+	result, err := tx.Exec(`INSERT INTO post_revision (post_id, title, body, edited_by, created_at)
+                       SELECT id, title, body, ?, ? FROM post
+                       WHERE id = ? AND (title IS NOT ? OR body IS NOT ?)`, editorID, now, postID, title, body)
+	if err != nil {
+		return false, fmt.Errorf("error saving revision: %v", err)
+	}
+	saved, _ := result.RowsAffected()
+	return saved > 0, nil
+}
+
+// Create - Keep the current version of a comment as a revision, unless the
+// new body is the same. Call it in the transaction that edits the comment;
+// it reports whether there is anything to edit.
+func commentRevisionInsert(tx *sql.Tx, commentID, editorID int, body, now string) (bool, error) {
+	result, err := tx.Exec(`INSERT INTO comment_revision (comment_id, body, edited_by, created_at)
+                       SELECT id, body, ?, ? FROM comment
+                       WHERE id = ? AND body IS NOT ?`, editorID, now, commentID, body)
+	if err != nil {
+		return false, fmt.Errorf("error saving revision: %v", err)
+	}
+	saved, _ := result.RowsAffected()
+	return saved > 0, nil
+}
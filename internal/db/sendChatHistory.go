@@ -1,125 +1,115 @@
-// SendChatHistory retrieves message history between two users
 package db
 
 import (
-	"encoding/json"
 	"fmt"
 	"models"
+)
 
-	"github.com/gorilla/websocket"
+// Page sizes accepted when loading a conversation
+const (
+	DefaultChatPageSize = 10
+	MaxChatPageSize     = 50
 )
 
-func SendChatHistory(user1ID, user2ID int, conn *websocket.Conn) error {
-	fmt.Println("Debug: Starting SendChatHistory for users", user1ID, "and", user2ID)
+// ChatHistory retrieves one page of the message history between two users:
+// the `limit` most recent messages older than message `before` (or the most
+// recent ones when before is 0), in chronological order.
+func (s *Store) ChatHistory(user1ID, user2ID, before, limit int) (*models.ChatHistory, error) {
+	if limit <= 0 {
+		limit = DefaultChatPageSize
+	} else if limit > MaxChatPageSize {
+		limit = MaxChatPageSize
+	}
 
 	// Getting the usernames of the two users
-	user1Name := UserNicknameWithID(user1ID)
-	user2Name := UserNicknameWithID(user2ID)
-
-	db := SetupDatabase()
-	defer db.Close()
+	user1Name := s.UserNicknameWithID(user1ID)
+	user2Name := s.UserNicknameWithID(user2ID)
 
-	tx, err := db.Begin()
+	tx, err := s.DB.Begin()
 	if err != nil {
-		// fmt.Println("Debug: Transaction error:", err)
-		return fmt.Errorf("error starting transaction: %v", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
-	// fmt.Println("Debug: Transaction started successfully")
 
 	// Note: Using "user" instead of "User" since that's the table name in your schema
-	query := `SELECT pm.sender_id, u_sender.nickName, pm.receiver_id, u_receiver.nickName, 
-			pm.message, pm.createdAt, pm.read 
+	query := `SELECT pm.id, pm.sender_id, u_sender.nickName, pm.receiver_id, u_receiver.nickName, 
+			pm.message, pm.createdAt, pm.read, pm.deleted_at IS NOT NULL
 			FROM private_message pm
 			JOIN user u_sender ON pm.sender_id = u_sender.id
 			JOIN user u_receiver ON pm.receiver_id = u_receiver.id
-			WHERE (pm.sender_id = ? AND pm.receiver_id = ?) 
-			OR (pm.sender_id = ? AND pm.receiver_id = ?)
-			ORDER BY pm.createdAt ASC`
-
-	// fmt.Println("Debug: Executing query:", query)
-	// fmt.Println("Debug: With params:", user1ID, user2ID, user2ID, user1ID)
-
-	rows, err := tx.Query(query, user1ID, user2ID, user2ID, user1ID)
+			WHERE ((pm.sender_id = ? AND pm.receiver_id = ?) 
+			OR (pm.sender_id = ? AND pm.receiver_id = ?))
+			AND (? = 0 OR pm.id < ?)
+			ORDER BY pm.id DESC
+			LIMIT ?`
+
+	// Fetch one extra row to know whether an older page exists
+	rows, err := tx.Query(query, user1ID, user2ID, user2ID, user1ID, before, before, limit+1)
 	if err != nil {
-		// fmt.Println("Debug: Query error:", err)
 		tx.Rollback()
-		return fmt.Errorf("error executing query: %v", err)
+		return nil, fmt.Errorf("error executing query: %v", err)
 	}
 	defer rows.Close()
-	// fmt.Println("Debug: Query executed successfully")
 
-	var messages []models.ChatHistoryMessage
-	messageCount := 0
+	messages := []models.ChatHistoryMessage{}
 
 	for rows.Next() {
-		var senderID, receiverID int
+		var id, senderID, receiverID int
 		var senderUsername, receiverUsername, message, createdAt string
 		var read int
+		var deleted bool
 
-		err := rows.Scan(&senderID, &senderUsername, &receiverID, &receiverUsername,
-			&message, &createdAt, &read)
-
+		err := rows.Scan(&id, &senderID, &senderUsername, &receiverID, &receiverUsername,
+			&message, &createdAt, &read, &deleted)
 		if err != nil {
-			fmt.Println("Debug: Scan error:", err)
 			tx.Rollback()
-			return fmt.Errorf("error scanning message: %v", err)
+			return nil, fmt.Errorf("error scanning message: %v", err)
 		}
 
-		messageCount++
-		// fmt.Printf("Debug: Scanned message %d: sender=%s, content=%s, time=%s\n",
-		// messageCount, senderUsername, message, createdAt)
-
 		// Convert the data to the appropriate format
 		chatMsg := models.ChatHistoryMessage{
+			ID:        id,
 			Type:      "chat_history_message",
 			SenderID:  senderID,
 			Sender:    senderUsername,
 			Message:   message,
 			Timestamp: createdAt,
 			Read:      read != 0,
+			Deleted:   deleted,
+		}
+		// Deleted messages keep their place in the conversation, not their text
+		if deleted {
+			chatMsg.Message = DeletedPlaceholder
 		}
 
 		messages = append(messages, chatMsg)
 	}
 
 	if err = rows.Err(); err != nil {
-		// fmt.Println("Debug: Row iterator error:", err)
 		tx.Rollback()
-		return fmt.Errorf("error iterating messages: %v", err)
+		return nil, fmt.Errorf("error iterating messages: %v", err)
 	}
 
-	fmt.Printf("Debug: Found %d messages between users\n", messageCount)
-
 	if err = tx.Commit(); err != nil {
-		// fmt.Println("Debug: Commit error:", err)
-		return fmt.Errorf("error committing transaction: %v", err)
+		return nil, fmt.Errorf("error committing transaction: %v", err)
 	}
-	// fmt.Println("Debug: Transaction committed successfully")
 
-	// Create the response containing the full chat history
-	response := models.ChatHistory{
-		Type:      "chat_history",
-		User1Name: user1Name,
-		User2Name: user2Name,
-		Messages:  messages,
-	}
-
-	fmt.Println("response: ", response)
-
-	// Convert response to JSON
-	jsonResponse, err := json.Marshal(response)
-	if err != nil {
-		// fmt.Println("Debug: JSON marshal error:", err)
-		return fmt.Errorf("error marshaling JSON: %v", err)
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
 	}
-	// fmt.Println("Debug: Response marshaled successfully")
 
-	// Send the chat history to the requesting client
-	if err := conn.WriteMessage(websocket.TextMessage, jsonResponse); err != nil {
-		// fmt.Println("Debug: WebSocket write error:", err)
-		return fmt.Errorf("error sending chat history: %v", err)
+	// Rows came newest first, the page is sent oldest first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
 	}
-	fmt.Println("Debug: Chat history sent successfully")
 
-	return nil
+	// Create the response containing this page of the chat history
+	return &models.ChatHistory{
+		Type:      "chat_history",
+		User1Name: user1Name,
+		User2Name: user2Name,
+		Messages:  messages,
+		Before:    before,
+		HasMore:   hasMore,
+	}, nil
 }
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+// testMessages has `from` send `count` numbered messages to `to` and
+// returns their IDs
+func testMessages(t *testing.T, s *Store, from, to, count int) []int {
+	t.Helper()
+
+	ids := make([]int, 0, count)
+	for i := 1; i <= count; i++ {
+		id, err := s.PrivateMessageInsert(from, to, fmt.Sprintf("message %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// historyPage loads a page of the history of userID with partnerID and
+// returns the IDs of its messages, in order, and whether there are more
+func historyPage(t *testing.T, s *Store, userID, partnerID, before, limit int) ([]int, bool) {
+	t.Helper()
+
+	history, err := s.ChatHistory(userID, partnerID, before, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0, len(history.Messages))
+	for _, m := range history.Messages {
+		ids = append(ids, m.ID)
+	}
+	return ids, history.HasMore
+}
+
+func TestChatHistoryPagesBackwards(t *testing.T) {
+	s := testStore(t)
+	alice := testUser(t, s, "alice")
+	bob := testUser(t, s, "bob")
+	carol := testUser(t, s, "carol")
+	ids := testMessages(t, s, alice, bob, 5)
+	testMessages(t, s, alice, carol, 2) // Another conversation
+
+	// The newest page, oldest first
+	page, more := historyPage(t, s, bob, alice, 0, 2)
+	if !equalIDs(page, ids[3:]) || !more {
+		t.Errorf("first page %v (more %v), want %v", page, more, ids[3:])
+	}
+	page, more = historyPage(t, s, bob, alice, page[0], 2)
+	if !equalIDs(page, ids[1:3]) || !more {
+		t.Errorf("second page %v (more %v), want %v", page, more, ids[1:3])
+	}
+	page, more = historyPage(t, s, bob, alice, page[0], 2)
+	if !equalIDs(page, ids[:1]) || more {
+		t.Errorf("last page %v (more %v), want %v", page, more, ids[:1])
+	}
+
+	// Exactly a page left is the last one too
+	if page, more = historyPage(t, s, bob, alice, ids[3], 3); !equalIDs(page, ids[:3]) || more {
+		t.Errorf("page of exactly the rest %v (more %v), want %v", page, more, ids[:3])
+	}
+}
+
+func TestChatHistoryClampsThePageSize(t *testing.T) {
+	s := testStore(t)
+	alice := testUser(t, s, "alice")
+	bob := testUser(t, s, "bob")
+	testMessages(t, s, alice, bob, MaxChatPageSize+1)
+
+	for _, tt := range []struct {
+		limit, want int
+	}{
+		{0, DefaultChatPageSize},
+		{-1, DefaultChatPageSize},
+		{5, 5},
+		{MaxChatPageSize + 100, MaxChatPageSize},
+	} {
+		history, err := s.ChatHistory(alice, bob, 0, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Messages) != tt.want || !history.HasMore {
+			t.Errorf("limit %d: %d messages (more %v), want %d", tt.limit, len(history.Messages), history.HasMore, tt.want)
+		}
+	}
+}
+
+func TestChatHistoryKeepsDeletedMessagesAsPlaceholders(t *testing.T) {
+	s := testStore(t)
+	alice := testUser(t, s, "alice")
+	bob := testUser(t, s, "bob")
+	ids := testMessages(t, s, alice, bob, 3)
+	if _, err := s.PrivateMessageDelete(ids[1], alice); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.ChatHistory(bob, alice, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Messages) != 3 {
+		t.Fatalf("%d messages, want the 3 with the deleted one", len(history.Messages))
+	}
+	for i, m := range history.Messages {
+		deleted := i == 1
+		if m.Deleted != deleted || (m.Message == DeletedPlaceholder) != deleted {
+			t.Errorf("message %d: deleted %v, text %q", m.ID, m.Deleted, m.Message)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"config"
+	"database/sql"
+	"fmt"
+	"models"
+	"time"
+)
+
+// Create - Record a new session. Only the hash of its token is stored.
+func (s *Store) SessionInsert(tokenHash string, userID int, expiresAt time.Time, userAgent, ip string) error {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	insertSQL := `INSERT INTO session (token_hash, user_id, created_at, expires_at, last_seen, user_agent, ip)
+                  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.DB.Exec(insertSQL, tokenHash, userID, now,
+		expiresAt.UTC().Format("2006-01-02 15:04:05"), now, userAgent, ip)
+	if err != nil {
+		return fmt.Errorf("error inserting session: %v", err)
+	}
+
+	return nil
+}
+
+// Columns of a session and its user, as scanned by scanSession
+const sessionQuery = `SELECT s.id, s.user_id, u.nickName, u.role, u.email_verified_at IS NOT NULL,
+              s.created_at, s.expires_at, s.last_seen, s.user_agent, s.ip
+              FROM session s
+              JOIN user u ON s.user_id = u.id AND u.deleted_at IS NULL`
+
+// sessionCutoffs returns the current time and the last_seen before which a
+// session is idle, formatted to be compared with the stored dates
+func sessionCutoffs() (string, string) {
+	now := time.Now().UTC()
+	return now.Format("2006-01-02 15:04:05"),
+		now.Add(-config.SESSION_IDLE_TIMEOUT).Format("2006-01-02 15:04:05")
+}
+
+// scanSession reads one row selected with sessionQuery
+func scanSession(row interface{ Scan(...any) error }) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.Username, &session.Role, &session.EmailVerified, &session.CreatedAt,
+		&session.ExpiresAt, &session.LastSeen, &session.UserAgent, &session.IP,
+	)
+	return &session, err
+}
+
+// Read - Get the session matching a token hash, with its user. It may have
+// expired, that's for the caller to check.
+func (s *Store) SessionSelectByTokenHash(tokenHash string) (*models.Session, error) {
+	session, err := scanSession(s.DB.QueryRow(sessionQuery+` WHERE s.token_hash = ?`, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no session for this token")
+		}
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+
+	return session, nil
+}
+
+// Read - Get a session by ID, with its user. It may have expired, that's
+// for the caller to check.
+func (s *Store) SessionSelectByID(sessionID int) (*models.Session, error) {
+	session, err := scanSession(s.DB.QueryRow(sessionQuery+` WHERE s.id = ?`, sessionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no session with ID %d", sessionID)
+		}
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+
+	return session, nil
+}
+
+// Read - Get the unexpired sessions of a user, most recently used first
+func (s *Store) SessionSelectByUserID(userID int) ([]models.Session, error) {
+	now, idle := sessionCutoffs()
+	query := sessionQuery + ` WHERE s.user_id = ? AND s.expires_at > ? AND s.last_seen > ?
+              ORDER BY s.last_seen DESC`
+	rows, err := s.DB.Query(query, userID, now, idle)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning session: %v", err)
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %v", err)
+	}
+
+	return sessions, nil
+}
+
+// Update - Record that a session was just used
+func (s *Store) SessionTouch(sessionID int) error {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	updateSQL := `UPDATE session SET last_seen = ? WHERE id = ?`
+	if _, err := s.DB.Exec(updateSQL, now, sessionID); err != nil {
+		return fmt.Errorf("error executing statement: %v", err)
+	}
+
+	return nil
+}
+
+// Delete - End the session matching a token hash
+func (s *Store) SessionDeleteByTokenHash(tokenHash string) error {
+	deleteSQL := `DELETE FROM session WHERE token_hash = ?`
+	if _, err := s.DB.Exec(deleteSQL, tokenHash); err != nil {
+		return fmt.Errorf("error executing statement: %v", err)
+	}
+
+	return nil
+}
+
+// Delete - End one session of a user. Returns false when the user has no
+// such session.
+func (s *Store) SessionDelete(userID, sessionID int) (bool, error) {
+	deleteSQL := `DELETE FROM session WHERE id = ? AND user_id = ?`
+	result, err := s.DB.Exec(deleteSQL, sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("error executing statement: %v", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error counting deleted sessions: %v", err)
+	}
+
+	return count > 0, nil
+}
+
+// Delete - End every session of a user
+func (s *Store) SessionDeleteByUserID(userID int) error {
+	deleteSQL := `DELETE FROM session WHERE user_id = ?`
+	if _, err := s.DB.Exec(deleteSQL, userID); err != nil {
+		return fmt.Errorf("error executing statement: %v", err)
+	}
+
+	return nil
+}
+
+// Delete - Purge the sessions that are past their lifetime or idle for too
+// long. Returns the IDs of the sessions removed.
+func (s *Store) SessionDeleteExpired() ([]int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	now, idle := sessionCutoffs()
+	rows, err := tx.Query(`SELECT id FROM session WHERE expires_at <= ? OR last_seen <= ?`, now, idle)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, fmt.Errorf("error scanning session: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("error iterating sessions: %v", err)
+	}
+
+	deleteSQL := `DELETE FROM session WHERE expires_at <= ? OR last_seen <= ?`
+	if _, err = tx.Exec(deleteSQL, now, idle); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("error executing statement: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return ids, nil
+}
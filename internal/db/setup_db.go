@@ -12,37 +12,20 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var DB *sql.DB
-
-func SetupDatabase() *sql.DB {
-	// Check if config is initialized
-	config.Initialize()
-
+// Open creates the connection pool used for the whole lifetime of the server.
+// The schema itself is managed by the migrations package.
+// Every connection of the pool runs in WAL mode with foreign keys enforced and
+// waits up to config.DB_BUSY_TIMEOUT when another connection holds the write lock.
+func Open() (*Store, error) {
 	// Check if running on Render (detect by PORT environment variable)
 	if os.Getenv("PORT") != "" {
 		log.Println("Running on Render, using in-memory database")
-		// Use in-memory database for Render
-		db, err := sql.Open("sqlite3", ":memory:")
+		store, err := OpenMemory()
 		if err != nil {
-			log.Printf("Error opening in-memory database: %v", err)
-			// Continue with normal setup as fallback
-		} else {
-			// Set up in-memory tables
-			DB = db
-			createUsersTable(db)
-			createPostsTable(db)
-			createCommentsTable(db)
-			createNotificationsTable(db)
-			createPrivateMessageTable(db)
-
-			// Add a demo user
-			_, err = db.Exec("INSERT INTO users (username, email, password, avatar, created_at) VALUES (?, ?, ?, ?, datetime('now'))",
-				"demo_user", "demo@example.com", "password_hash", "default_avatar",
-			)
-
-			log.Println("In-memory database setup complete")
-			return db
+			return nil, err
 		}
+		log.Println("In-memory database setup complete")
+		return store, nil
 	}
 
 	/***********************************************************************
@@ -51,50 +34,38 @@ func SetupDatabase() *sql.DB {
 	* go run -tags sqlite_userauth cmd/golang-server-layout/main.go
 	* You can use the tag to go test the authentication auth_test.go
 	/**********************************************************************/
-	connString := fmt.Sprintf("%s?_auth&_auth_user=%s&_auth_pass=%s&_auth_crypt=sha256",
-		config.DB_PATH, config.DB_USER, config.DB_PW)
-
-	// Check if the database file exists
-	dbExists := false
-	if _, err := os.Stat(config.DB_PATH); err == nil {
-		dbExists = true
-	}
+	connString := fmt.Sprintf("%s?_auth&_auth_user=%s&_auth_pass=%s&_auth_crypt=sha256"+
+		"&_journal_mode=WAL&_busy_timeout=%d&_foreign_keys=on",
+		config.DB_PATH, config.DB_USER, config.DB_PW, config.DB_BUSY_TIMEOUT)
 
 	// Open or create the database file
 	db, err := sql.Open("sqlite3", connString)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("error opening database: %v", err)
 	}
+	db.SetMaxOpenConns(config.DB_MAX_OPEN_CONNS)
+	db.SetMaxIdleConns(config.DB_MAX_OPEN_CONNS)
 
-	// Set global DB variable
-	DB = db
-
-	// Only create tables if the database doesn't exist
-	if !dbExists {
-		fmt.Println("Creating new database tables...")
-		createUsersTable(db)
-		createPostsTable(db)
-		createCommentsTable(db)
-		createNotificationsTable(db)
-		createPrivateMessageTable(db)
+	// sql.Open is lazy, make sure the file can actually be reached
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to database: %v", err)
 	}
 
-	return db
+	return &Store{DB: db}, nil
 }
 
-// executeSQL prepares and executes a given SQL statement.
-// It logs any errors that occur during preparation or execution.
-func executeSQL(db *sql.DB, sql string) {
-	// Prepare the SQL statement for execution
-	statement, err := db.Prepare(sql)
+// OpenMemory creates an empty database living in memory, as used on Render
+// and by the tests. Each connection to ":memory:" gets its own database, so
+// the pool is pinned to a single connection that is never recycled.
+func OpenMemory() (*Store, error) {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
 	if err != nil {
-		log.Fatal(err) // Log and terminate if there is an error preparing the statement
+		return nil, fmt.Errorf("error opening in-memory database: %v", err)
 	}
-	defer statement.Close()
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
 
-	// Execute the prepared statement
-	_, err = statement.Exec()
-	if err != nil {
-		log.Fatal(err) // Log and terminate if there is an error executing the statement
-	}
+	return &Store{DB: db}, nil
 }
@@ -2,48 +2,31 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-func createUsersTable(db *sql.DB) {
-	createTableSQL := `CREATE TABLE IF NOT EXISTS "user" (
-	"id"	INTEGER NOT NULL UNIQUE,
-	"uuid"  TEXT NOT NULL UNIQUE,
-	"nickName"	TEXT NOT NULL UNIQUE,
-	"gender"	TEXT NOT NULL,
-	"firstName"	TEXT NOT NULL,
-	"lastName"	TEXT NOT NULL,
-	"email"	TEXT NOT NULL UNIQUE,
-	"password"	TEXT NOT NULL,
-	"role"	TEXT NOT NULL,
-	"connected" INTEGER NOT NULL DEFAULT 0,
-	PRIMARY KEY("id" AUTOINCREMENT))`
-
-	executeSQL(db, createTableSQL)
-}
-
 type User struct {
-	ID        int
-	UUID      string
-	NickName  string
-	Gender    string
-	FirstName string
-	LastName  string
-	Email     string
-	Password  string // This will be hashed and not returned in most queries
-	Role      string
+	ID            int
+	UUID          string
+	NickName      string
+	Gender        string
+	FirstName     string
+	LastName      string
+	Email         string
+	Password      string // This will be hashed and not returned in most queries
+	Role          string
+	EmailVerified bool // Set once the user opened the link sent to Email
 }
 
 // Create - Register a new user
-func UserInsert(uuid, nickName, gender, firstName, lastName, email, password, role string, connected int) (int, string) {
-	db := SetupDatabase()
-	defer db.Close()
-
+func (s *Store) UserInsert(uuid, nickName, gender, firstName, lastName, email, password, role string, connected int) (int, string) {
 	// Check if username already exists
 	var existingUserID int
-	err := db.QueryRow("SELECT id FROM User WHERE nickName = ?", nickName).Scan(&existingUserID)
+	err := s.DB.QueryRow("SELECT id FROM User WHERE nickName = ?", nickName).Scan(&existingUserID)
 	if err == nil {
 		return 0, "Username already taken"
 	} else if err != sql.ErrNoRows {
@@ -51,14 +34,14 @@ func UserInsert(uuid, nickName, gender, firstName, lastName, email, password, ro
 	}
 
 	// Check if email already exists
-	err = db.QueryRow("SELECT id FROM User WHERE email = ?", email).Scan(&existingUserID)
+	err = s.DB.QueryRow("SELECT id FROM User WHERE email = ?", email).Scan(&existingUserID)
 	if err == nil {
 		return 0, "Email already registered"
 	} else if err != sql.ErrNoRows {
 		return 0, "Error checking email"
 	}
 
-	tx, err := db.Begin()
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return 0, "Error starting transaction"
 	}
@@ -93,22 +76,19 @@ func UserInsert(uuid, nickName, gender, firstName, lastName, email, password, ro
 }
 
 // Read - Get user by ID
-func UserSelectByID(userID int) (*User, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) UserSelectByID(userID int) (*User, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	query := `SELECT id, nickName, gender, firstName, lastName, email, role 
-             FROM User WHERE id = ?`
+	query := `SELECT id, nickName, gender, firstName, lastName, email, role, email_verified_at IS NOT NULL 
+             FROM User WHERE id = ? AND deleted_at IS NULL`
 
 	var user User
 	err = tx.QueryRow(query, userID).Scan(
 		&user.ID, &user.NickName, &user.Gender, &user.FirstName,
-		&user.LastName, &user.Email, &user.Role,
+		&user.LastName, &user.Email, &user.Role, &user.EmailVerified,
 	)
 
 	if err != nil {
@@ -127,17 +107,14 @@ func UserSelectByID(userID int) (*User, error) {
 }
 
 // Read - Get user by nickname or email (for login)
-func UserSelectByCredentials(login string) (*User, string) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) UserSelectByCredentials(login string) (*User, string) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, "Error starting transaction"
 	}
 
 	query := `SELECT id, uuid, nickName, gender, firstName, lastName, email, password, role 
-             FROM User WHERE nickName = ? OR email = ?`
+             FROM User WHERE (nickName = ? OR email = ?) AND deleted_at IS NULL`
 
 	var user User
 	err = tx.QueryRow(query, login, login).Scan(
@@ -148,7 +125,7 @@ func UserSelectByCredentials(login string) (*User, string) {
 	if err != nil {
 		tx.Rollback()
 		if err == sql.ErrNoRows {
-			return nil, "No user found with those credentials"
+			return nil, "Invalid username or password"
 		}
 		return nil, "Error executing query"
 	}
@@ -160,17 +137,22 @@ func UserSelectByCredentials(login string) (*User, string) {
 	return &user, "nil"
 }
 
-// Authenticate user
-func UserAuthenticate(login, password string) (*User, string) {
-	user, err := UserSelectByCredentials(login)
+// dummyHash is compared against when the login matches no account, so both
+// failures take as long and can't be told apart
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// Authenticate user. Unknown logins and wrong passwords fail the same way.
+func (s *Store) UserAuthenticate(login, password string) (*User, string) {
+	user, err := s.UserSelectByCredentials(login)
 	if err != "nil" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
 		return nil, err
 	}
 
 	// Compare password with hash
 	errPassword := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if errPassword != nil {
-		return nil, "Invalid password"
+		return nil, "Invalid username or password"
 	}
 
 	// Clear password before returning
@@ -179,11 +161,8 @@ func UserAuthenticate(login, password string) (*User, string) {
 }
 
 // Update - Update user information
-func UserUpdate(userID int, nickName, gender, firstName, lastName, email, role string) error {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) UserUpdate(userID int, nickName, gender, firstName, lastName, email, role string) error {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -204,12 +183,82 @@ func UserUpdate(userID int, nickName, gender, firstName, lastName, email, role s
 	return nil
 }
 
-// Update - Change password
-func UserUpdatePassword(userID int, newPassword string) error {
-	db := SetupDatabase()
-	defer db.Close()
+// Errors returned when a nickname or an email belongs to another account
+var (
+	ErrNicknameTaken = errors.New("nickname already taken")
+	ErrEmailTaken    = errors.New("email already registered")
+)
+
+// Update - Change the profile of a user. The nickname is copied on their
+// posts and comments, which are renamed too. A new email has to be verified
+// again.
+func (s *Store) UserUpdateProfile(userID int, nickName, gender, firstName, lastName, email string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	// Nicknames and emails stay unique, like at registration
+	var existingUserID int
+	err = tx.QueryRow("SELECT id FROM User WHERE nickName = ? AND id != ?", nickName, userID).Scan(&existingUserID)
+	if err == nil {
+		tx.Rollback()
+		return ErrNicknameTaken
+	} else if err != sql.ErrNoRows {
+		tx.Rollback()
+		return fmt.Errorf("error checking username: %v", err)
+	}
+
+	err = tx.QueryRow("SELECT id FROM User WHERE email = ? AND id != ?", email, userID).Scan(&existingUserID)
+	if err == nil {
+		tx.Rollback()
+		return ErrEmailTaken
+	} else if err != sql.ErrNoRows {
+		tx.Rollback()
+		return fmt.Errorf("error checking email: %v", err)
+	}
+
+	updateSQL := `UPDATE User SET nickName=?, gender=?, firstName=?, lastName=?, email=?,
+                 email_verified_at = CASE WHEN email = ? THEN email_verified_at ELSE NULL END
+                 WHERE id=?`
+	if _, err = tx.Exec(updateSQL, nickName, gender, firstName, lastName, email, email, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error executing statement: %v", err)
+	}
+
+	if _, err = tx.Exec(`UPDATE post SET user = ? WHERE user_id = ?`, nickName, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error renaming posts: %v", err)
+	}
+	if _, err = tx.Exec(`UPDATE comment SET user = ? WHERE user_id = ?`, nickName, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error renaming comments: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Read - Check the password of a user
+func (s *Store) UserCheckPassword(userID int, password string) bool {
+	var hashedPassword string
+	err := s.DB.QueryRow("SELECT password FROM User WHERE id = ?", userID).Scan(&hashedPassword)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			fmt.Println("Error getting the user's password:", err)
+		}
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+}
 
-	tx, err := db.Begin()
+// Update - Change password
+func (s *Store) UserUpdatePassword(userID int, newPassword string) error {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
 	}
@@ -236,42 +285,86 @@ func UserUpdatePassword(userID int, newPassword string) error {
 	return nil
 }
 
-// Delete - Delete user
-func UserDelete(userID int) error {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+// Delete - Mark a user as deleted, along with everything they posted and
+// their conversations. What goes with the user is marked with their ID in
+// deleted_with_user, which is how UserRestore tells it apart from what was
+// deleted on its own.
+func (s *Store) UserDelete(userID, deletedBy int) error {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	deleteSQL := `DELETE FROM User WHERE id=?`
-	_, err = tx.Exec(deleteSQL, userID)
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE user SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, []interface{}{now, userID}},
+		{`UPDATE post SET deleted_at = ?, deleted_by = ?, deleted_with_user = ?
+          WHERE user_id = ? AND deleted_at IS NULL`, []interface{}{now, deletedBy, userID, userID}},
+		{`UPDATE comment SET deleted_at = ?, deleted_by = ?, deleted_with_user = ?
+          WHERE user_id = ? AND deleted_at IS NULL`, []interface{}{now, deletedBy, userID, userID}},
+		{`UPDATE private_message SET deleted_at = ?, deleted_with_user = ?
+          WHERE (sender_id = ? OR receiver_id = ?) AND deleted_at IS NULL`, []interface{}{now, userID, userID, userID}},
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement.query, statement.args...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error deleting user: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Update - Bring back a deleted user and what was deleted with them. Returns
+// false when the user doesn't exist or isn't deleted.
+func (s *Store) UserRestore(userID int) (bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	// Only what UserDelete marked, not what was deleted on its own
+	statements := []string{
+		`UPDATE post SET deleted_at = NULL, deleted_by = NULL, deleted_with_user = NULL WHERE deleted_with_user = ?`,
+		`UPDATE comment SET deleted_at = NULL, deleted_by = NULL, deleted_with_user = NULL WHERE deleted_with_user = ?`,
+		`UPDATE private_message SET deleted_at = NULL, deleted_with_user = NULL WHERE deleted_with_user = ?`,
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement, userID); err != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("error restoring user: %v", err)
+		}
+	}
 
+	result, err := tx.Exec(`UPDATE user SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, userID)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error executing statement: %v", err)
+		return false, fmt.Errorf("error restoring user: %v", err)
 	}
+	restored, _ := result.RowsAffected()
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+		return false, fmt.Errorf("error committing transaction: %v", err)
 	}
 
-	return nil
+	return restored > 0, nil
 }
 
 // List all users
-func UserSelectAll() ([]User, error) {
-	db := SetupDatabase()
-	defer db.Close()
-
-	tx, err := db.Begin()
+func (s *Store) UserSelectAll() ([]User, error) {
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	query := `SELECT id, nickName, gender, firstName, lastName, email, role FROM User`
+	query := `SELECT id, nickName, gender, firstName, lastName, email, role FROM User WHERE deleted_at IS NULL`
 
 	rows, err := tx.Query(query)
 	if err != nil {
@@ -303,29 +396,11 @@ func UserSelectAll() ([]User, error) {
 	return users, nil
 }
 
-func UserNicknameWithUUID(uuid string) string {
-	// Setup database and variable to return
-	db := SetupDatabase()
-	var nickName string
-
-	// Unlogging the User in the database
-	state := `SELECT nickName FROM user WHERE uuid = ?`
-	err_db := db.QueryRow(state, uuid).Scan(&nickName)
-	if err_db != nil {
-		fmt.Println("Error getting user's nickname")
-	}
-
-	return nickName
-}
-
-func UserIDWithUUID(uuid string) int {
-	// Setup database and variable to return
-	db := SetupDatabase()
+func (s *Store) UserIDWithNickname(nickName string) int {
 	var id int
 
-	// Unlogging the User in the database
-	state := `SELECT id FROM user WHERE uuid = ?`
-	err_db := db.QueryRow(state, uuid).Scan(&id)
+	state := `SELECT id FROM user WHERE nickName = ? AND deleted_at IS NULL`
+	err_db := s.DB.QueryRow(state, nickName).Scan(&id)
 	if err_db != nil {
 		fmt.Printf("Error getting the user's id")
 	}
@@ -333,30 +408,64 @@ func UserIDWithUUID(uuid string) int {
 	return id
 }
 
-func UserIDWithNickname(nickName string) int {
-	// Setup database and variable to return
-	db := SetupDatabase()
+// Read - Get the ID of the user a login (nickname or email) belongs to, 0
+// if there is none
+func (s *Store) UserIDWithLogin(login string) int {
 	var id int
 
-	state := `SELECT id FROM user WHERE nickName = ?`
-	err_db := db.QueryRow(state, nickName).Scan(&id)
-	if err_db != nil {
-		fmt.Printf("Error getting the user's id")
+	state := `SELECT id FROM user WHERE (nickName = ? OR email = ?) AND deleted_at IS NULL`
+	if err := s.DB.QueryRow(state, login, login).Scan(&id); err != nil && err != sql.ErrNoRows {
+		fmt.Println("Error getting the user's id:", err)
 	}
 
 	return id
 }
 
-func UserNicknameWithID(id int) string {
-	// Setup database and variable to return
-	db := SetupDatabase()
+func (s *Store) UserNicknameWithID(id int) string {
 	var nickName string
 
 	state := `SELECT nickName FROM user WHERE id = ?`
-	err_db := db.QueryRow(state, id).Scan(&nickName)
+	err_db := s.DB.QueryRow(state, id).Scan(&nickName)
 	if err_db != nil {
 		fmt.Printf("Error getting the user's nickname")
 	}
 
 	return nickName
 }
+
+// Update - Flag the user as logged in or out
+func (s *Store) UserSetConnected(userID int, connected int) error {
+	state := `UPDATE user SET connected = ? WHERE id = ?`
+	if _, err := s.DB.Exec(state, connected, userID); err != nil {
+		return fmt.Errorf("error executing statement: %v", err)
+	}
+	return nil
+}
+
+// Read - List users by their connected flag
+func (s *Store) UserSelectByConnected(connected int) ([]User, error) {
+	query := `SELECT id, nickName, gender, firstName, lastName, email, role 
+	          FROM "user" WHERE connected = ? AND deleted_at IS NULL`
+
+	rows, err := s.DB.Query(query, connected)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.NickName, &user.Gender, &user.FirstName,
+			&user.LastName, &user.Email, &user.Role); err != nil {
+			return nil, fmt.Errorf("error scanning user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %v", err)
+	}
+
+	return users, nil
+}
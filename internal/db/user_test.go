@@ -0,0 +1,131 @@
+package db
+
+import (
+	"db/migrations"
+	"testing"
+)
+
+// testStore opens an in-memory database with the whole schema
+func testStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := migrations.Up(s.DB); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testUser registers a user and returns their ID
+func testUser(t *testing.T, s *Store, nickname string) int {
+	t.Helper()
+
+	id, msg := s.UserInsert(nickname+"-uuid", nickname, "Other", "First", "Last",
+		nickname+"@example.com", "Passw0rd!", "User", 0)
+	if id == 0 {
+		t.Fatalf("creating %s: %s", nickname, msg)
+	}
+	return id
+}
+
+// testPost publishes a post of userID in the seeded General category
+func testPost(t *testing.T, s *Store, userID int, title string) int {
+	t.Helper()
+
+	general, err := s.CategoryIDWithName("General")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := s.PostInsert(userID, title, "body", []int{general})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return post.ID
+}
+
+func TestUserRestoreOnlyBringsBackWhatWentWithTheUser(t *testing.T) {
+	s := testStore(t)
+	moderator := testUser(t, s, "moderator")
+	author := testUser(t, s, "author")
+	kept := testPost(t, s, author, "kept")
+	removed := testPost(t, s, author, "removed")
+
+	// Deleted on its own, in the same second as the account
+	if err := s.PostDelete(removed, moderator); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UserDelete(author, moderator); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PostSelectByID(kept); err == nil {
+		t.Fatal("post of a deleted user is still visible")
+	}
+	if _, err := s.PostSelectDeletedByID(kept); err == nil {
+		t.Fatal("post deleted with its author can be restored on its own")
+	}
+
+	restored, err := s.UserRestore(author)
+	if err != nil || !restored {
+		t.Fatalf("UserRestore = %v, %v", restored, err)
+	}
+	if _, err := s.PostSelectByID(kept); err != nil {
+		t.Errorf("post deleted with its author wasn't restored: %v", err)
+	}
+	if _, err := s.PostSelectByID(removed); err == nil {
+		t.Error("post deleted by a moderator was restored with its author")
+	}
+
+	if restored, _ := s.UserRestore(author); restored {
+		t.Error("restoring a user that isn't deleted reported success")
+	}
+}
+
+func TestUserUpdateProfileRenamesPostsAndComments(t *testing.T) {
+	s := testStore(t)
+	author := testUser(t, s, "author")
+	other := testUser(t, s, "other")
+	postID := testPost(t, s, author, "mine")
+	otherPost := testPost(t, s, other, "theirs")
+	if _, err := s.CommentInsert(author, otherPost, "my comment"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CommentInsert(other, postID, "their comment"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UserUpdateProfile(author, "renamed", "Other", "First", "Last", "author@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(query string) map[int]string {
+		rows, err := s.DB.Query(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		byUser := map[int]string{}
+		for rows.Next() {
+			var userID int
+			var name string
+			if err := rows.Scan(&userID, &name); err != nil {
+				t.Fatal(err)
+			}
+			byUser[userID] = name
+		}
+		return byUser
+	}
+	for _, table := range []string{"post", "comment"} {
+		byUser := names(`SELECT user_id, user FROM ` + table)
+		if byUser[author] != "renamed" || byUser[other] != "other" {
+			t.Errorf("%s authors after the rename: %v", table, byUser)
+		}
+	}
+
+	if err := s.UserUpdateProfile(author, "other", "Other", "First", "Last", "author@example.com"); err != ErrNicknameTaken {
+		t.Errorf("taking another nickname: %v, want %v", err, ErrNicknameTaken)
+	}
+}
@@ -3,12 +3,12 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
+	"middlewares"
 	"net/http"
 	"strconv"
-	"strings"
+	"validation"
 
-	"db"
 	"models"
 )
 
@@ -18,7 +18,7 @@ func UserSelectAllHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := db.UserSelectAll()
+	users, err := store.UserSelectAll()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -42,7 +42,7 @@ func GetUserByIdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := db.UserSelectByID(userID)
+	user, err := store.UserSelectByID(userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -52,89 +52,25 @@ func GetUserByIdHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
-func FetchPostsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	posts, err := db.PostSelectAll()
-	if err != nil {
-		// Add more detailed logging
-		log.Printf("Error fetching posts: %v", err)
-		http.Error(w, "Failed to fetch posts: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Verify posts are not empty
-	if len(posts) == 0 {
-		log.Println("No posts found")
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
-}
-
-func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var post models.Post
-	err := json.NewDecoder(r.Body).Decode(&post)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Add logging to debug
-	log.Printf("Received post: %+v", post)
-
-	// Ensure you're passing the correct parameters
-	createdPost, err := db.PostInsert(post.UserID, "", post.Title, post.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(createdPost)
-}
-
-// internal/handlers/api.go
-// Fix the FetchPostCommentsHandler function to correctly extract the post ID
-
+// FetchPostCommentsHandler lists the comments of a post, deleted ones as
+// placeholders: GET /api/posts/{id}/comments
 func FetchPostCommentsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Extract post ID from URL path - format: /api/posts/{postId}/comments
-	path := r.URL.Path
-
-	// Split the path and validate it has enough parts
-	pathParts := strings.Split(path, "/")
-
-	// Validate path has enough parts (should be like ["", "api", "posts", "{postId}", "comments"])
-	if len(pathParts) < 5 || pathParts[1] != "api" || pathParts[2] != "posts" || pathParts[4] != "comments" {
-		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
 		return
 	}
 
-	// The postId should be at index 3 in the path parts array
-	postIDStr := pathParts[3]
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+	// A deleted post's thread goes with it
+	if _, err := store.PostSelectByID(postID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
 		return
 	}
 
-	// Now fetch comments with the extracted postID
-	comments, err := db.CommentSelectByPostID(postID)
+	comments, err := store.CommentSelectByPostID(postID)
 	if err != nil {
-		http.Error(w, "Error fetching comments: "+err.Error(), http.StatusInternalServerError)
+		fmt.Println("Error fetching comments:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching comments")
 		return
 	}
 
@@ -142,54 +78,43 @@ func FetchPostCommentsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(comments)
 }
 
+// CreateCommentHandler adds a comment to a post: POST /api/posts/{id}/comments.
+// A refused body gets a 422 with the field error.
 func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
 		return
 	}
 
-	// Extract post ID from URL path - format: /api/posts/{postId}/comments
-	path := r.URL.Path
-	pathParts := strings.Split(path, "/")
-
-	// Validate path structure
-	if len(pathParts) < 4 {
-		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+	var comment models.Comment
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize)).Decode(&comment); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	// The postId should be at index 3 in the path parts array
-	postIDStr := pathParts[3]
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+	var v validation.Validator
+	v.Content("body", comment.Body)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
 		return
 	}
 
-	var comment models.Comment
-	err = json.NewDecoder(r.Body).Decode(&comment)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if _, err := store.PostSelectByID(postID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
 		return
 	}
 
-	// Set the postID from the URL
-	comment.PostID = postID
-
-	// Checking the cookie values
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		http.Error(w, "Error retrieving session", http.StatusUnauthorized)
-		return
-	}
-	userID := db.UserIDWithUUID(cookie.Value)
+	// The author is whoever is logged in
+	user, _ := middlewares.CurrentUser(r)
 
-	// Insert the new comment into the database
-	createdComment, err := db.CommentInsert(userID, cookie.Value, comment.PostID, comment.Body)
+	createdComment, err := store.CommentInsert(user.ID, postID, comment.Body)
 	if err != nil {
-		http.Error(w, "Error creating comment", http.StatusInternalServerError)
+		fmt.Println("Error creating comment:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error creating comment")
 		return
 	}
+	sendCommentEvent("comment_created", createdComment)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(createdComment)
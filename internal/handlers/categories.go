@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"validation"
+)
+
+// HandleFetchCategories lists the categories with their number of posts:
+// GET /api/categories
+func HandleFetchCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := store.CategorySelectAll()
+	if err != nil {
+		fmt.Println("Error fetching categories:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching categories")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// postCategoryIDs resolves the category names a post is filed under. A post
+// needs at least one, and only existing ones; problems go to v.
+func postCategoryIDs(v *validation.Validator, names []string) []int {
+	if len(names) == 0 {
+		v.Add("categories", validation.CodeRequired, "Pick at least one category")
+		return nil
+	}
+
+	ids := make([]int, 0, len(names))
+	for _, name := range names {
+		id, err := store.CategoryIDWithName(name)
+		if err != nil {
+			fmt.Println("Error looking up category:", err)
+		}
+		if id == 0 {
+			v.Add("categories", validation.CodeInvalid, fmt.Sprintf("Unknown category %q", name))
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -1,18 +1,13 @@
 package handlers
 
 import (
-	"database/sql"
-	"db"
 	"encoding/json"
-	"fmt"
+	"middlewares"
 	"net/http"
 )
 
 // Function to check the session with the cookie and database request
 func CheckSession(w http.ResponseWriter, r *http.Request) {
-	// Get the db
-	db := db.SetupDatabase()
-
 	// Get the session cookie
 	cookie, err := r.Cookie("session_id")
 
@@ -25,17 +20,12 @@ func CheckSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Checking if the uuid stored in the cookie is valid
-	var nickname string
-	state := `SELECT nickName FROM user WHERE uuid = ?`
-	errQuery := db.QueryRow(state, cookie.Value).Scan(&nickname)
-	// fmt.Println("error: ", errQuery)
-
-	// If it's not contained, log out the user
-	if errQuery == sql.ErrNoRows {
-		LogOutHandler(w, r)
-	} else if errQuery != nil {
-		fmt.Println("Error checking cookie session: ", errQuery)
+	// Checking if the cookie still maps to a live session
+	// If it doesn't, tell the javascript why (session_expired or session_invalid)
+	if _, err := middlewares.LookupSession(cookie.Value); err != nil {
+		middlewares.ClearSessionCookie(w)
+		json.NewEncoder(w).Encode(map[string]interface{}{"loggedIn": false, "reason": err.Error()})
+		return
 	}
 
 	// Valid session
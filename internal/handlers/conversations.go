@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"db"
+	"encoding/json"
+	"fmt"
+	"middlewares"
+	"net/http"
+	"strconv"
+)
+
+// HandleConversations lists the session user's conversations, the one with
+// the most recent message first: GET /api/conversations
+func HandleConversations(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	conversations, err := store.ConversationList(user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching conversations")
+		return
+	}
+
+	online := onlineUsers()
+	for i := range conversations {
+		conversations[i].Online = online[conversations[i].User]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"conversations": conversations})
+}
+
+// HandleConversationMessages returns one page of the conversation between the
+// session user and {user}: GET /api/conversations/{user}/messages?before=&limit=
+func HandleConversationMessages(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	partnerID := store.UserIDWithNickname(r.PathValue("user"))
+	if partnerID == 0 {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Both parameters are optional, an empty cursor means the latest page
+	query := r.URL.Query()
+	before, err := optionalInt(query.Get("before"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid before cursor")
+		return
+	}
+	limit, err := optionalInt(query.Get("limit"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	history, err := store.ChatHistory(user.ID, partnerID, before, limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching messages")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// onlineUsers returns the set of users connected to the chat
+func onlineUsers() map[string]bool {
+	online := make(map[string]bool)
+	for _, username := range chat.Online() {
+		online[username] = true
+	}
+	return online
+}
+
+// optionalInt parses a non-negative integer query parameter, "" meaning 0
+func optionalInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return n, nil
+}
+
+// writeJSONError sends an error as {"error": message} with the given status
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// HandleDeleteMessage deletes a message its sender regrets: DELETE
+// /api/messages/{id}. Both sides see a placeholder in its place until it is
+// restored or purged.
+func HandleDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	messageID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	receiverID, err := store.PrivateMessageDelete(messageID, user.ID)
+	if err != nil {
+		fmt.Println("Error deleting message:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error deleting message")
+		return
+	}
+	// Someone else's message is as good as missing
+	if receiverID == 0 {
+		writeJSONError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+	sendMessageChange("message_deleted", messageID, user.Username, store.UserNicknameWithID(receiverID), db.DeletedPlaceholder)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRestoreMessage brings back a message its sender deleted: POST
+// /api/messages/{id}/restore
+func HandleRestoreMessage(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	messageID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	receiverID, message, err := store.PrivateMessageRestore(messageID, user.ID)
+	if err != nil {
+		fmt.Println("Error restoring message:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error restoring message")
+		return
+	}
+	if receiverID == 0 {
+		writeJSONError(w, http.StatusNotFound, "Deleted message not found")
+		return
+	}
+	sendMessageChange("message_restored", messageID, user.Username, store.UserNicknameWithID(receiverID), message)
+
+	w.WriteHeader(http.StatusNoContent)
+}
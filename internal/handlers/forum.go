@@ -1 +1,33 @@
 package handlers
+
+import (
+	"db"
+	"hub"
+	"mailer"
+	"middlewares"
+)
+
+// store is the database shared by every handler, set once by Init
+var store *db.Store
+
+// chat routes every WebSocket frame sent by the server
+var chat *hub.Hub
+
+// mail delivers the emails sent to users, set once by Init
+var mail mailer.Mailer
+
+// Init hands the handlers the store opened at startup and the mailer, and
+// starts the chat hub and the session janitor
+func Init(s *db.Store, m mailer.Mailer) {
+	store = s
+	mail = m
+	chat = hub.New(userListMessage)
+	go chat.Run()
+
+	// Sockets don't outlive the session they were opened with
+	go middlewares.RunJanitor(func(sessionIDs []int) {
+		closeSessionSockets(middlewares.ErrSessionExpired.Error(), sessionIDs...)
+	})
+	// Deleted content can be restored for a while, then it goes for good
+	go runRetention()
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"config"
 	"db"
 	"encoding/json"
 	"fmt"
+	"math"
 	"middlewares"
 	"models"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -24,28 +28,97 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse to even check the password while the address or the account
+	// is cooling down from failed attempts. The attempt counts as a failure
+	// until the password turns out right.
+	ip := middlewares.ClientIP(r)
+	accountID := store.UserIDWithLogin(req.Name)
+	since := time.Now().Add(-config.LOGIN_ATTEMPT_WINDOW)
+	attemptID, wait, err := store.LoginAttemptStart(req.Name, accountID, ip, r.UserAgent(), since, loginRetryAfter)
+	if err != nil {
+		fmt.Println("Error recording login attempt:", err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(models.RegisterResponse{
+			Success: false,
+			Message: fmt.Sprintf("Too many failed attempts, try again in %s", formatWait(seconds)),
+		})
+		return
+	}
+
 	// Authenticate the user using the database
-	user, errorDB := db.UserAuthenticate(req.Name, req.Password)
+	user, errorDB := store.UserAuthenticate(req.Name, req.Password)
 
 	// Checking if the authentication failed
 	if errorDB != "nil" {
+		fmt.Printf("Failed login for %q from %s\n", req.Name, ip)
+
 		response := models.RegisterResponse{Success: false, Message: errorDB}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 
+	// A successful login starts the account's count of failures over
+	if err := store.LoginAttemptSucceeded(attemptID); err != nil {
+		fmt.Println("Error recording login attempt:", err)
+	}
+
 	// Create a session for the authenticated user
-	middlewares.CreateSession(w, user.ID, user.NickName, user.Role, user.UUID)
+	if err := middlewares.CreateSession(w, r, user.ID); err != nil {
+		fmt.Println("Error creating session:", err)
+		http.Error(w, "Error creating session", http.StatusInternalServerError)
+		return
+	}
 
-	db := db.SetupDatabase()
-	// Unlogging the User in the database
-	state := `UPDATE user SET connected = ? WHERE uuid = ?`
-	_, err_db := db.Exec(state, 1, user.UUID)
-	if err_db != nil {
-		fmt.Printf("Error logging in")
+	// Logging the User in the database
+	if err := store.UserSetConnected(user.ID, 1); err != nil {
+		fmt.Println("Error logging in:", err)
 	}
 
 	// If authentication succeeded, notify the client of the success
 	json.NewEncoder(w).Encode(models.RegisterResponse{Success: true, Message: "Login successful"})
 }
+
+// loginRetryAfter tells how long the address and the account must wait
+// before their next login attempt, 0 if they may try now
+func loginRetryAfter(failures db.LoginFailures) time.Duration {
+	wait := loginBackoff(failures.IP, failures.IPLast, config.LOGIN_IP_FREE_ATTEMPTS, config.LOGIN_IP_MAX_FAILURES)
+	if w := loginBackoff(failures.Account, failures.AccountLast, config.LOGIN_FREE_ATTEMPTS, config.LOGIN_MAX_FAILURES); w > wait {
+		wait = w
+	}
+	return wait
+}
+
+// loginBackoff returns how long to wait after the last of `failures` failed
+// attempts: nothing for the first free ones, then config.LOGIN_BACKOFF
+// doubling with each failure, and config.LOGIN_LOCKOUT from maxFailures on
+func loginBackoff(failures int, last time.Time, free, maxFailures int) time.Duration {
+	if failures < free {
+		return 0
+	}
+
+	delay := config.LOGIN_LOCKOUT
+	if failures < maxFailures {
+		delay = config.LOGIN_BACKOFF << (failures - free)
+		if delay > config.LOGIN_LOCKOUT || delay <= 0 {
+			delay = config.LOGIN_LOCKOUT
+		}
+	}
+
+	return time.Until(last.Add(delay))
+}
+
+// formatWait writes a number of seconds for people
+func formatWait(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%d second(s)", seconds)
+	}
+	return fmt.Sprintf("%d minute(s)", (seconds+59)/60)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"config"
+	"db"
+	"middlewares"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// near reports whether two durations are within a second of each other
+func near(got, want time.Duration) bool {
+	diff := got - want
+	return diff > -time.Second && diff < time.Second
+}
+
+func TestLoginBackoff(t *testing.T) {
+	free, maxFailures := 3, 5
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		failures int
+		last     time.Time
+		want     time.Duration
+	}{
+		{"free attempts", free - 1, now, 0},
+		{"first failure over", free, now, config.LOGIN_BACKOFF},
+		{"doubles", free + 1, now, 2 * config.LOGIN_BACKOFF},
+		{"locked out", maxFailures, now, config.LOGIN_LOCKOUT},
+		{"lockout over", maxFailures, now.Add(-config.LOGIN_LOCKOUT - time.Minute), -time.Minute},
+	}
+	for _, tt := range tests {
+		if got := loginBackoff(tt.failures, tt.last, free, maxFailures); !near(got, tt.want) {
+			t.Errorf("%s: loginBackoff(%d) = %s, want %s", tt.name, tt.failures, got, tt.want)
+		}
+	}
+
+	// However many doublings, the wait never goes past the lockout
+	if got := loginBackoff(200, now, free, 1000); !near(got, config.LOGIN_LOCKOUT) {
+		t.Errorf("loginBackoff(200) = %s, want the lockout %s", got, config.LOGIN_LOCKOUT)
+	}
+}
+
+func TestLoginRetryAfterTakesTheLongestWait(t *testing.T) {
+	now := time.Now()
+	failures := db.LoginFailures{
+		IP:          config.LOGIN_IP_FREE_ATTEMPTS - 1,
+		IPLast:      now,
+		Account:     config.LOGIN_MAX_FAILURES,
+		AccountLast: now,
+	}
+	if got := loginRetryAfter(failures); !near(got, config.LOGIN_LOCKOUT) {
+		t.Errorf("locked account from a fresh address: wait %s, want %s", got, config.LOGIN_LOCKOUT)
+	}
+
+	failures.Account = 0
+	if got := loginRetryAfter(failures); got > 0 {
+		t.Errorf("nothing to wait for: wait %s", got)
+	}
+}
+
+func TestLoginIsRefusedAfterTooManyFailures(t *testing.T) {
+	server := setupServer(t)
+	loggedIn(t, "member", middlewares.RoleUser)
+
+	// Checking passwords is slow, the backoff mustn't run out between logins
+	backoff := config.LOGIN_BACKOFF
+	config.LOGIN_BACKOFF = time.Minute
+	t.Cleanup(func() { config.LOGIN_BACKOFF = backoff })
+
+	login := func(password string) *http.Response {
+		resp, err := http.Post(server.URL+"/login", "application/json",
+			strings.NewReader(`{"username": "member", "password": "`+password+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for i := 0; i < config.LOGIN_FREE_ATTEMPTS; i++ {
+		if resp := login("wrong"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("failure %d: status %d", i+1, resp.StatusCode)
+		}
+	}
+
+	// Even the right password waits out the backoff
+	resp := login("Passw0rd!")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("login after %d failures: status %d, want 429", config.LOGIN_FREE_ATTEMPTS, resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("no Retry-After on a refused login")
+	}
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"db"
+	"encoding/json"
+	"fmt"
+	"middlewares"
+	"models"
+	"net/http"
+	"strconv"
+	"validation"
+)
+
+// HandleUpdatePost changes the title and content of a post, for its author
+// or a moderator: PUT (or PATCH) /api/posts/{id}, with the same fields and
+// checks as a new post. The previous version goes to the post's history; an
+// edit changing nothing is answered with the post as it is, and no one else
+// hears of it.
+func HandleUpdatePost(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+	post, err := store.PostSelectByID(postID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if !user.CanModify(post.UserID) {
+		writeJSONError(w, http.StatusForbidden, "You are not allowed to edit this post")
+		return
+	}
+
+	var req PostRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	var v validation.Validator
+	v.Title("title", req.Title)
+	v.Content("content", req.Body)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
+		return
+	}
+
+	changed, err := store.PostUpdateContent(postID, user.ID, req.Title, req.Body)
+	if err != nil {
+		fmt.Println("Error updating post:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error updating post")
+		return
+	}
+
+	post, err = store.PostSelectByID(postID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching post")
+		return
+	}
+	if changed {
+		sendPostEvent("post_updated", post)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// HandleDeletePost removes a post and its comments, for its author or a
+// moderator: DELETE /api/posts/{id}. They can be restored until the
+// retention job purges them.
+func HandleDeletePost(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+	post, err := store.PostSelectByID(postID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if !user.CanModify(post.UserID) {
+		writeJSONError(w, http.StatusForbidden, "You are not allowed to delete this post")
+		return
+	}
+
+	if err := store.PostDelete(postID, user.ID); err != nil {
+		fmt.Println("Error deleting post:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error deleting post")
+		return
+	}
+	sendPostEvent("post_deleted", post)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRestorePost brings back a deleted post with its comments, for a
+// moderator or the author who deleted it: POST /api/posts/{id}/restore
+func HandleRestorePost(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+	post, err := store.PostSelectDeletedByID(postID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Deleted post not found")
+		return
+	}
+	if !user.CanRestore(post.UserID, post.DeletedBy) {
+		writeJSONError(w, http.StatusForbidden, "You are not allowed to restore this post")
+		return
+	}
+
+	if err := store.PostRestore(postID); err != nil {
+		fmt.Println("Error restoring post:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error restoring post")
+		return
+	}
+
+	post, err = store.PostSelectByID(postID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching post")
+		return
+	}
+	sendPostEvent("post_restored", post)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// HandleUpdateComment changes the body of a comment, for its author or a
+// moderator: PUT /api/posts/{id}/comments/{cid}, or PATCH /api/comments/{id}.
+// The previous version goes to the comment's history; an edit changing
+// nothing is answered with the comment as it is, and no one else hears of it.
+func HandleUpdateComment(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	comment, ok := requestedComment(w, r)
+	if !ok {
+		return
+	}
+	if !user.CanModify(comment.UserID) {
+		writeJSONError(w, http.StatusForbidden, "You are not allowed to edit this comment")
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	var v validation.Validator
+	v.Content("body", req.Body)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
+		return
+	}
+
+	changed, err := store.CommentUpdate(comment.ID, user.ID, req.Body)
+	if err != nil {
+		fmt.Println("Error updating comment:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error updating comment")
+		return
+	}
+
+	comment, err = store.CommentSelectByID(comment.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching comment")
+		return
+	}
+	if changed {
+		sendCommentEvent("comment_updated", comment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// HandleDeleteComment removes a comment, for its author or a moderator:
+// DELETE /api/posts/{id}/comments/{cid}, or DELETE /api/comments/{id}. Its
+// thread shows a placeholder until it is restored or purged.
+func HandleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	comment, ok := requestedComment(w, r)
+	if !ok {
+		return
+	}
+	if !user.CanModify(comment.UserID) {
+		writeJSONError(w, http.StatusForbidden, "You are not allowed to delete this comment")
+		return
+	}
+
+	if err := store.CommentDelete(comment.ID, user.ID); err != nil {
+		fmt.Println("Error deleting comment:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error deleting comment")
+		return
+	}
+	sendCommentEvent("comment_deleted", db.DeletedComment(comment))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRestoreComment brings back a deleted comment, for a moderator or the
+// author who deleted it: POST /api/posts/{id}/comments/{cid}/restore
+func HandleRestoreComment(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	comment, ok := loadRequestedComment(w, r, store.CommentSelectDeletedByID)
+	if !ok {
+		return
+	}
+	if !user.CanRestore(comment.UserID, comment.DeletedBy) {
+		writeJSONError(w, http.StatusForbidden, "You are not allowed to restore this comment")
+		return
+	}
+
+	if err := store.CommentRestore(comment.ID); err != nil {
+		fmt.Println("Error restoring comment:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error restoring comment")
+		return
+	}
+
+	comment, err := store.CommentSelectByID(comment.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching comment")
+		return
+	}
+	sendCommentEvent("comment_restored", comment)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// HandlePostRevisions lists the earlier versions of a post, latest first:
+// GET /api/posts/{id}/revisions
+func HandlePostRevisions(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+	if _, err := store.PostSelectByID(postID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	revisions, err := store.PostRevisions(postID)
+	if err != nil {
+		fmt.Println("Error fetching revisions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// HandleCommentRevisions lists the earlier versions of a comment, latest
+// first: GET /api/posts/{id}/comments/{cid}/revisions
+func HandleCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	comment, ok := requestedComment(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := store.CommentRevisions(comment.ID)
+	if err != nil {
+		fmt.Println("Error fetching revisions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// requestedComment loads the comment of /api/posts/{id}/comments/{cid} or
+// /api/comments/{id}, and answers the request itself when there is none
+func requestedComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	return loadRequestedComment(w, r, store.CommentSelectByID)
+}
+
+// loadRequestedComment is requestedComment with the query to use, e.g. to
+// find deleted comments instead
+func loadRequestedComment(w http.ResponseWriter, r *http.Request, selectComment func(int) (*models.Comment, error)) (*models.Comment, bool) {
+	commentValue, postValue := r.PathValue("id"), ""
+	if cid := r.PathValue("cid"); cid != "" {
+		commentValue, postValue = cid, r.PathValue("id")
+	}
+
+	commentID, err := strconv.Atoi(commentValue)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid comment ID")
+		return nil, false
+	}
+	comment, err := selectComment(commentID)
+	// A comment is only found under the post it belongs to
+	if err != nil || (postValue != "" && postValue != strconv.Itoa(comment.PostID)) {
+		writeJSONError(w, http.StatusNotFound, "Comment not found")
+		return nil, false
+	}
+	return comment, true
+}
+
+// HandleUpdateUserRole gives a user another role, for admins only:
+// PATCH /api/users/{id}/role
+func HandleUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+	if !user.Can(middlewares.ManageRoles) {
+		writeJSONError(w, http.StatusForbidden, "Only administrators can change roles")
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	// Admins can't lock themselves out of the admin pages
+	if userID == user.ID {
+		writeJSONError(w, http.StatusForbidden, "You can't change your own role")
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !middlewares.ValidRole(req.Role) {
+		writeJSONError(w, http.StatusBadRequest, "Unknown role")
+		return
+	}
+
+	target, err := store.UserSelectByID(userID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	err = store.UserUpdate(target.ID, target.NickName, target.Gender, target.FirstName,
+		target.LastName, target.Email, req.Role)
+	if err != nil {
+		fmt.Println("Error updating role:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error updating role")
+		return
+	}
+	target.Role = req.Role
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       target.ID,
+		"nickName": target.NickName,
+		"role":     target.Role,
+	})
+}
+
+// HandleDeleteUser deletes an account along with everything its owner posted
+// and their conversations, for admins only: DELETE /api/users/{id}. The user
+// is logged out everywhere; it can all be restored until the retention job
+// purges it.
+func HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+	if !user.Can(middlewares.ManageUsers) {
+		writeJSONError(w, http.StatusForbidden, "Only administrators can delete users")
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if userID == user.ID {
+		writeJSONError(w, http.StatusForbidden, "You can't delete your own account")
+		return
+	}
+	if _, err := store.UserSelectByID(userID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Sessions of a deleted user can't be looked up anymore, so they go first
+	if err := revokeUserSessions(userID); err != nil {
+		fmt.Println("Error revoking sessions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error deleting user")
+		return
+	}
+	if err := store.UserDelete(userID, user.ID); err != nil {
+		fmt.Println("Error deleting user:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error deleting user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRestoreUser brings back a deleted account and what was deleted with
+// it, for admins only: POST /api/users/{id}/restore
+func HandleRestoreUser(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+	if !user.Can(middlewares.ManageUsers) {
+		writeJSONError(w, http.StatusForbidden, "Only administrators can restore users")
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	restored, err := store.UserRestore(userID)
+	if err != nil {
+		fmt.Println("Error restoring user:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error restoring user")
+		return
+	}
+	if !restored {
+		writeJSONError(w, http.StatusNotFound, "Deleted user not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"middlewares"
+	"models"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDeleteUserClosesTheirSockets(t *testing.T) {
+	server := setupServer(t)
+	_, adminToken := loggedIn(t, "admin", middlewares.RoleAdmin)
+	userID, userToken := loggedIn(t, "member", middlewares.RoleUser)
+	conn := dial(t, server, userToken)
+
+	status, _ := call(t, server, http.MethodDelete, "/api/users/"+strconv.Itoa(userID), adminToken, "")
+	if status != http.StatusNoContent {
+		t.Fatalf("DELETE /api/users/%d: status %d", userID, status)
+	}
+	if !closedWith(t, conn, websocket.ClosePolicyViolation) {
+		t.Error("the deleted user's socket wasn't closed with 1008")
+	}
+}
+
+func TestEditsAreCheckedLikeNewContent(t *testing.T) {
+	server := setupServer(t)
+	_, token := loggedIn(t, "author", middlewares.RoleUser)
+	post := createPost(t, server, token, "Original")
+	postPath := "/api/posts/" + strconv.Itoa(post.ID)
+	status, body := call(t, server, http.MethodPost, postPath+"/comments", token, `{"body": "Original"}`)
+	if status != http.StatusOK {
+		t.Fatalf("creating comment: status %d: %s", status, body)
+	}
+	var comment models.Comment
+	if err := json.Unmarshal([]byte(body), &comment); err != nil {
+		t.Fatal(err)
+	}
+	commentPath := postPath + "/comments/" + strconv.Itoa(comment.ID)
+
+	status, body = call(t, server, http.MethodPut, commentPath, token, `{"body": " \n "}`)
+	if fields := strings.Join(refusedFields(t, status, body), ","); fields != "body" {
+		t.Errorf("blank comment edit: refused fields = %s, want body", fields)
+	}
+	status, body = call(t, server, http.MethodPut, postPath, token,
+		`{"title": "`+strings.Repeat("a", 201)+`", "content": "Edited"}`)
+	if fields := strings.Join(refusedFields(t, status, body), ","); fields != "title" {
+		t.Errorf("long title: refused fields = %s, want title", fields)
+	}
+	status, _ = call(t, server, http.MethodPut, postPath, token,
+		`{"title": "Edited", "content": "`+strings.Repeat("a", 70<<10)+`"}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("oversized edit: status %d, want 400", status)
+	}
+
+	revisions, err := store.PostRevisions(post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 0 {
+		t.Errorf("refused edits left %d revision(s)", len(revisions))
+	}
+
+	status, body = call(t, server, http.MethodPut, postPath, token, `{"title": "Edited", "content": "New body"}`)
+	if status != http.StatusOK || !strings.Contains(body, "New body") {
+		t.Errorf("valid edit: status %d: %s", status, body)
+	}
+}
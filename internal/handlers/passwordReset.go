@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"config"
+	"encoding/json"
+	"fmt"
+	"middlewares"
+	"models"
+	"net/http"
+	"net/url"
+	"time"
+	"validation"
+)
+
+// HandlePasswordResetRequest emails a link to choose a new password:
+// POST /api/password-reset. The account is looked up and the email sent in
+// the background, and the answer is the same whether the account exists or
+// not, so it can't be used to find out who is registered. Each login and
+// address can only ask for a few links per config.PASSWORD_RESET_WINDOW.
+func HandlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Login string `json:"login"` // Nickname or email
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Login == "" {
+		writeJSONError(w, http.StatusBadRequest, "A nickname or an email is required")
+		return
+	}
+
+	since := time.Now().Add(-config.PASSWORD_RESET_WINDOW)
+	allowed, err := store.PasswordResetRequestInsert(req.Login, middlewares.ClientIP(r), since,
+		config.PASSWORD_RESET_MAX_REQUESTS, config.PASSWORD_RESET_IP_MAX_REQUESTS)
+	if err != nil {
+		fmt.Println("Error recording password reset request:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error requesting a password reset")
+		return
+	}
+	if !allowed {
+		writeJSONError(w, http.StatusTooManyRequests, "Too many password reset requests, try again later")
+		return
+	}
+
+	go func(login string) {
+		if userID := store.UserIDWithLogin(login); userID != 0 {
+			if err := sendPasswordReset(userID); err != nil {
+				fmt.Println("Error sending password reset:", err)
+			}
+		}
+	}(req.Login)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RegisterResponse{
+		Success: true,
+		Message: "If this account exists, a link to reset its password has been sent to its email address",
+	})
+}
+
+// sendPasswordReset issues a new reset token for a user and emails the link
+func sendPasswordReset(userID int) error {
+	user, err := store.UserSelectByID(userID)
+	if err != nil {
+		return err
+	}
+
+	token := middlewares.GenerateToken()
+	expiresAt := time.Now().Add(config.PASSWORD_RESET_LIFETIME)
+	if err := store.PasswordResetInsert(user.ID, middlewares.HashToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	link := config.APP_URL + "/?reset_token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Someone asked to reset the password of your account. To choose a new one, open this link:\n\n"+
+		"%s\n\n"+
+		"The link can be used once and expires in %s. If you didn't ask for it, you can ignore this email.\n",
+		user.NickName, link, config.PASSWORD_RESET_LIFETIME)
+	return mail.Send(user.Email, "Reset your password", body)
+}
+
+// HandlePasswordResetConfirm sets the password chosen through a reset link
+// and logs the account out everywhere: POST /api/password-reset/confirm
+func HandlePasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	var v validation.Validator
+	v.Password("password", req.Password)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
+		return
+	}
+
+	userID, err := store.PasswordResetConsume(middlewares.HashToken(req.Token))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "This reset link is invalid or has expired")
+		return
+	}
+
+	if err := store.UserUpdatePassword(userID, req.Password); err != nil {
+		fmt.Println("Error resetting password:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error resetting password")
+		return
+	}
+
+	// Whoever knew the old password is logged out
+	if err := revokeUserSessions(userID); err != nil {
+		fmt.Println("Error revoking sessions:", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RegisterResponse{
+		Success: true,
+		Message: "Your password has been changed, you can now log in",
+	})
+}
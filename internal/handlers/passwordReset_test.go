@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"config"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPasswordResetRequestsAreLimitedPerLogin(t *testing.T) {
+	server := setupServer(t)
+
+	request := func(login string) int {
+		resp, err := http.Post(server.URL+"/api/password-reset", "application/json",
+			strings.NewReader(`{"login": "`+login+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i := 0; i < config.PASSWORD_RESET_MAX_REQUESTS; i++ {
+		if status := request("someone"); status != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, status)
+		}
+	}
+	if status := request("SomeOne"); status != http.StatusTooManyRequests {
+		t.Errorf("request over the limit: status %d, want 429", status)
+	}
+	if status := request("someone-else"); status != http.StatusOK {
+		t.Errorf("another login from the same address: status %d", status)
+	}
+}
@@ -4,190 +4,140 @@ import (
 	"db"
 	"encoding/json"
 	"fmt"
+	"middlewares"
 	"models"
 	"net/http"
-	"strconv"
-	"time"
-
-	"github.com/gorilla/mux"
+	"validation"
 )
 
-type Post struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Username  string    `json:"user"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
-}
-
 // PostRequest represents the incoming request structure
 type PostRequest struct {
-	Title string `json:"title"`
-	Body  string `json:"content"`
+	Title      string   `json:"title"`
+	Body       string   `json:"content"`
+	Categories []string `json:"categories"` // Names of the categories
 }
 
-// HandleFetchPosts handles fetching all posts
+// HandleFetchPosts handles fetching a page of the post feed:
+// GET /api/posts?sort=&limit=&before=&after=&category=
+// sort is newest (the default), comments or active. before takes the
+// next_cursor of a page to get the one below it, after takes a prev_cursor to
+// get the posts above it, such as the ones created since.
 func HandleFetchPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := db.PostSelectAll()
-	if err != nil {
-		http.Error(w, "Error fetching posts", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
-}
-
-// HandleCreatePost handles the creation of new posts
-func HandleCreatePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	query := r.URL.Query()
+	feed := db.FeedQuery{Sort: query.Get("sort")}
+	if feed.Sort == "" {
+		feed.Sort = db.FeedNewest
+	} else if !db.ValidFeedSort(feed.Sort) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid sort")
 		return
 	}
 
-	var postReq PostRequest
-	err := json.NewDecoder(r.Body).Decode(&postReq)
+	limit, err := optionalInt(query.Get("limit"))
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid limit")
 		return
 	}
+	feed.Limit = limit
 
-	// Checking the cookie values
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		http.Error(w, "Error retrieving session", http.StatusUnauthorized)
+	before, after := query.Get("before"), query.Get("after")
+	if before != "" && after != "" {
+		writeJSONError(w, http.StatusBadRequest, "Use either before or after")
 		return
 	}
-	userID := db.UserIDWithUUID(cookie.Value)
-
-	// Create a Post from the PostRequest
-	post := models.Post{
-		UserID: userID,
-		Title:  postReq.Title,
-		Body:   postReq.Body,
+	if feed.Before, err = feedCursor(before, feed.Sort); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+		return
 	}
-
-	// Insert the new post into the database
-	createdPost, err := db.PostInsert(post.UserID, cookie.Value, post.Title, post.Body)
-	if err != nil {
-		http.Error(w, "Error creating post", http.StatusInternalServerError)
+	if feed.After, err = feedCursor(after, feed.Sort); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(createdPost)
-}
-
-// HandleFetchNewPosts handles requests for new posts since a given post ID
-func HandleFetchNewPosts(w http.ResponseWriter, r *http.Request) {
-	// Set content type
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get last post ID from query parameter
-	lastIDStr := r.URL.Query().Get("lastID")
-	lastID, err := strconv.Atoi(lastIDStr)
-	if err != nil {
-		http.Error(w, "Invalid last ID", http.StatusBadRequest)
-		return
+	if name := query.Get("category"); name != "" {
+		id, err := store.CategoryIDWithName(name)
+		if err != nil {
+			fmt.Println("Error looking up category:", err)
+			writeJSONError(w, http.StatusInternalServerError, "Error fetching posts")
+			return
+		}
+		if id == 0 {
+			writeJSONError(w, http.StatusNotFound, "Unknown category")
+			return
+		}
+		feed.CategoryID = id
 	}
 
-	// Query for new posts
-	newPosts, err := getNewPosts(lastID)
+	page, err := store.PostSelectFeed(feed)
 	if err != nil {
-		http.Error(w, "Error fetching new posts", http.StatusInternalServerError)
+		fmt.Println("Error fetching posts:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching posts")
 		return
 	}
 
-	// Return posts as JSON
-	json.NewEncoder(w).Encode(newPosts)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
 }
 
-// getNewPosts fetches posts newer than the specified ID
-func getNewPosts(lastID int) ([]Post, error) {
-	query := `
-		SELECT p.id, p.user_id, p.user, p.title, p.body, p.createdAt, p.updatedAt
-		FROM post p
-		WHERE p.id > ?
-		ORDER BY p.id DESC
-		LIMIT 20
-	`
-
-	rows, err := db.DB.Query(query, lastID)
+// feedCursor parses an optional cursor, which must come from a page in the
+// same sort order
+func feedCursor(value, sort string) (*db.FeedCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	cursor, err := db.ParseFeedCursor(value)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		var createdAtStr, updatedAtStr string
-
-		err := rows.Scan(
-			&post.ID,
-			&post.UserID,
-			&post.Username,
-			&post.Title,
-			&post.Body,
-			&createdAtStr,
-			&updatedAtStr,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// Parse datetime strings
-		post.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAtStr)
-		post.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAtStr)
-
-		posts = append(posts, post)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("cursor is for sort %q", cursor.Sort)
 	}
-
-	return posts, nil
+	return &cursor, nil
 }
 
-func HandleFetchPostComments(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	postID, _ := strconv.Atoi(vars["postId"])
+// HandleCreatePost handles the creation of new posts. A refused title,
+// content or category list gets a 422 with the field errors.
+func HandleCreatePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Invalid request method")
+		return
+	}
 
-	comments, err := db.CommentSelectByPostID(postID)
+	var postReq PostRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize)).Decode(&postReq)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
-}
+	// The author is whoever is logged in
+	user, _ := middlewares.CurrentUser(r)
+	userID := user.ID
 
-func HandleCreateComment(w http.ResponseWriter, r *http.Request) {
-	var comment models.Comment
-	err := json.NewDecoder(r.Body).Decode(&comment)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	// Create a Post from the PostRequest
+	post := models.Post{
+		UserID: userID,
+		Title:  postReq.Title,
+		Body:   postReq.Body,
 	}
 
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		fmt.Println("Error accessing cookie: ", err)
-		http.Error(w, "Authentication required", http.StatusUnauthorized)
+	var v validation.Validator
+	v.Title("title", post.Title)
+	v.Content("content", post.Body)
+	categoryIDs := postCategoryIDs(&v, postReq.Categories)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
 		return
 	}
-	id := db.UserIDWithUUID(cookie.Value)
 
-	createdComment, err := db.CommentInsert(id, cookie.Value, comment.PostID, comment.Body)
+	// Insert the new post into the database
+	createdPost, err := store.PostInsert(post.UserID, post.Title, post.Body, categoryIDs)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		fmt.Println("Error creating post:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error creating post")
 		return
 	}
+	sendPostEvent("post_created", createdPost)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(createdComment)
+	json.NewEncoder(w).Encode(createdPost)
 }
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"middlewares"
+	"models"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// refusedFields returns the fields named by a 422 answer, sorted
+func refusedFields(t *testing.T, status int, body string) []string {
+	t.Helper()
+
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want 422: %s", status, body)
+	}
+	var answer models.RegisterResponse
+	if err := json.Unmarshal([]byte(body), &answer); err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, e := range answer.Errors {
+		fields = append(fields, e.Field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// createPost publishes a post in General through the API and returns it
+func createPost(t *testing.T, server *httptest.Server, token, title string) models.Post {
+	t.Helper()
+
+	status, body := call(t, server, http.MethodPost, "/api/postCreation", token,
+		`{"title": "`+title+`", "content": "body", "categories": ["General"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("creating post: status %d: %s", status, body)
+	}
+	var post models.Post
+	if err := json.Unmarshal([]byte(body), &post); err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func TestCreatePostRefusesEmptyFields(t *testing.T) {
+	server := setupServer(t)
+	_, token := loggedIn(t, "author", middlewares.RoleUser)
+
+	status, body := call(t, server, http.MethodPost, "/api/postCreation", token,
+		`{"title": " ", "content": "", "categories": ["General"]}`)
+	if fields := strings.Join(refusedFields(t, status, body), ","); fields != "content,title" {
+		t.Errorf("refused fields = %s, want content and title", fields)
+	}
+}
+
+func TestCreateCommentRefusesAnEmptyBody(t *testing.T) {
+	server := setupServer(t)
+	_, token := loggedIn(t, "author", middlewares.RoleUser)
+	post := createPost(t, server, token, "Thread")
+	path := "/api/posts/" + strconv.Itoa(post.ID) + "/comments"
+
+	status, body := call(t, server, http.MethodPost, path, token, `{"body": "  "}`)
+	if fields := strings.Join(refusedFields(t, status, body), ","); fields != "body" {
+		t.Errorf("refused fields = %s, want body", fields)
+	}
+
+	if status, body := call(t, server, http.MethodPost, path, token, `{"body": "First!"}`); status != http.StatusOK {
+		t.Fatalf("valid comment: status %d: %s", status, body)
+	}
+	status, body = call(t, server, http.MethodGet, path, "", "")
+	if status != http.StatusOK || !strings.Contains(body, "First!") || strings.Count(body, `"ID"`) != 1 {
+		t.Errorf("comments of the post: status %d: %s", status, body)
+	}
+
+	if status, _ := call(t, server, http.MethodPost, "/api/posts/999/comments", token, `{"body": "Lost"}`); status != http.StatusNotFound {
+		t.Errorf("comment on a missing post: status %d, want 404", status)
+	}
+}
+
+func TestFetchPostsFiltersByCategory(t *testing.T) {
+	server := setupServer(t)
+	_, token := loggedIn(t, "author", middlewares.RoleUser)
+	general := createPost(t, server, token, "General news")
+	status, body := call(t, server, http.MethodPost, "/api/postCreation", token,
+		`{"title": "Tech news", "content": "body", "categories": ["Tech"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("creating post: status %d: %s", status, body)
+	}
+
+	for _, name := range []string{"General", "general"} {
+		status, body := call(t, server, http.MethodGet, "/api/posts?category="+name, "", "")
+		if status != http.StatusOK {
+			t.Fatalf("category %s: status %d: %s", name, status, body)
+		}
+		var page models.FeedPage
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Posts) != 1 || page.Posts[0].ID != general.ID {
+			t.Errorf("category %s: %d posts", name, len(page.Posts))
+		}
+	}
+
+	if status, body := call(t, server, http.MethodGet, "/api/posts?category=Cooking", "", ""); status != http.StatusNotFound {
+		t.Errorf("unknown category: status %d: %s", status, body)
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"config"
+	"db"
+	"encoding/json"
+	"fmt"
+	"math"
+	"middlewares"
+	"models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"validation"
+)
+
+// profile is the account of the session user as the frontend sees it,
+// named like the registration form
+type profile struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+	Gender    string `json:"gender"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	// Whether Email is verified, read only
+	EmailVerified bool `json:"email_verified"`
+}
+
+// HandleUpdateProfile changes the profile of the session user:
+// PATCH /api/me. Fields left out of the body keep their value.
+func HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	current, err := store.UserSelectByID(user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Decoding over the current values only replaces the fields sent
+	req := profile{
+		Username:  current.NickName,
+		Firstname: current.FirstName,
+		Lastname:  current.LastName,
+		Gender:    current.Gender,
+		Email:     current.Email,
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+
+	// Only what changes is checked, accounts created before the rules
+	// existed can still edit their other fields
+	var v validation.Validator
+	if req.Firstname != current.FirstName {
+		v.Name("firstname", "First name", req.Firstname)
+	}
+	if req.Lastname != current.LastName {
+		v.Name("lastname", "Last name", req.Lastname)
+	}
+	if req.Username != current.NickName {
+		v.Nickname("username", req.Username)
+	}
+	if req.Email != current.Email {
+		v.Email("email", req.Email)
+	}
+	if req.Gender != current.Gender {
+		v.Gender("gender", req.Gender)
+	}
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
+		return
+	}
+
+	// A new address gets a verification email, which is rate limited
+	if req.Email != current.Email {
+		allowed, err := verificationEmailAllowed(user.ID, req.Email)
+		if err != nil {
+			fmt.Println("Error recording email verification:", err)
+			writeJSONError(w, http.StatusInternalServerError, "Error updating profile")
+			return
+		}
+		if !allowed {
+			writeJSONError(w, http.StatusTooManyRequests, "Too many email changes, try again later")
+			return
+		}
+	}
+
+	err = store.UserUpdateProfile(user.ID, req.Username, req.Gender, req.Firstname, req.Lastname, req.Email)
+	switch err {
+	case nil:
+	case db.ErrNicknameTaken:
+		writeJSONError(w, http.StatusConflict, "Username already taken")
+		return
+	case db.ErrEmailTaken:
+		writeJSONError(w, http.StatusConflict, "Email already registered")
+		return
+	default:
+		fmt.Println("Error updating profile:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error updating profile")
+		return
+	}
+
+	// The chat knows users by nickname: move the live connections and let
+	// every page rename the user in its lists and chat tabs
+	if req.Username != current.NickName {
+		chat.Rename(current.NickName, req.Username)
+		sendUserRenamed(current.NickName, req.Username)
+	}
+
+	// A new address has to be verified again
+	req.EmailVerified = current.EmailVerified
+	if req.Email != current.Email {
+		req.EmailVerified = false
+		if err := sendEmailVerification(user.ID); err != nil {
+			fmt.Println("Error sending email verification:", err)
+		}
+	}
+
+	req.ID = user.ID
+	req.Role = current.Role
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// HandleChangePassword changes the password of the session user, who must
+// know the current one: POST /api/me/password. Wrong current passwords count
+// as failed logins of the account, so they are throttled the same way. Their
+// other devices are logged out.
+func HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	var v validation.Validator
+	v.Password("new_password", req.NewPassword)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
+		return
+	}
+
+	since := time.Now().Add(-config.LOGIN_ATTEMPT_WINDOW)
+	attemptID, wait, err := store.LoginAttemptStart(user.Username, user.ID, middlewares.ClientIP(r), r.UserAgent(), since, loginRetryAfter)
+	if err != nil {
+		fmt.Println("Error recording login attempt:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error changing password")
+		return
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		writeJSONError(w, http.StatusTooManyRequests,
+			fmt.Sprintf("Too many failed attempts, try again in %s", formatWait(seconds)))
+		return
+	}
+	if !store.UserCheckPassword(user.ID, req.CurrentPassword) {
+		writeJSONError(w, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+	if err := store.LoginAttemptSucceeded(attemptID); err != nil {
+		fmt.Println("Error recording login attempt:", err)
+	}
+
+	if err := store.UserUpdatePassword(user.ID, req.NewPassword); err != nil {
+		fmt.Println("Error changing password:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error changing password")
+		return
+	}
+
+	// Only this device stays logged in
+	sessions, err := store.SessionSelectByUserID(user.ID)
+	if err != nil {
+		fmt.Println("Error fetching sessions:", err)
+	}
+	var revoked []int
+	for _, s := range sessions {
+		if s.ID == user.SessionID {
+			continue
+		}
+		if _, err := store.SessionDelete(user.ID, s.ID); err != nil {
+			fmt.Println("Error revoking session:", err)
+			continue
+		}
+		revoked = append(revoked, s.ID)
+	}
+	closeSessionSockets("session_revoked", revoked...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RegisterResponse{Success: true, Message: "Password changed"})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"config"
+	"hub"
+	"middlewares"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChangePasswordIsThrottledLikeLogins(t *testing.T) {
+	server := setupServer(t)
+	userID, token := loggedIn(t, "member", middlewares.RoleUser)
+
+	// Checking passwords is slow, the backoff mustn't run out between tries
+	backoff := config.LOGIN_BACKOFF
+	config.LOGIN_BACKOFF = time.Minute
+	t.Cleanup(func() { config.LOGIN_BACKOFF = backoff })
+
+	change := func(current string) int {
+		status, _ := call(t, server, http.MethodPost, "/api/me/password", token,
+			`{"current_password": "`+current+`", "new_password": "N3w-passw0rd!"}`)
+		return status
+	}
+
+	for i := 0; i < config.LOGIN_FREE_ATTEMPTS; i++ {
+		if status := change("wrong"); status != http.StatusForbidden {
+			t.Fatalf("failure %d: status %d", i+1, status)
+		}
+	}
+
+	// Even the right password waits out the backoff
+	if status := change("Passw0rd!"); status != http.StatusTooManyRequests {
+		t.Fatalf("change after %d failures: status %d, want 429", config.LOGIN_FREE_ATTEMPTS, status)
+	}
+	if !store.UserCheckPassword(userID, "Passw0rd!") {
+		t.Error("the password changed while throttled")
+	}
+}
+
+func TestUpdateProfileRenamesTheOpenSockets(t *testing.T) {
+	server := setupServer(t)
+	_, token := loggedIn(t, "member", middlewares.RoleUser)
+	conn := dial(t, server, token)
+	frameOfType(t, conn, "user_list")
+
+	if status, body := call(t, server, http.MethodPatch, "/api/me", token, `{"username": "renamed"}`); status != http.StatusOK {
+		t.Fatalf("PATCH /api/me: status %d: %s", status, body)
+	}
+	if frame := frameOfType(t, conn, "user_renamed"); frame.Sender != "member" || frame.Message != "renamed" {
+		t.Errorf("rename frame from %s to %s", frame.Sender, frame.Message)
+	}
+
+	for _, username := range chat.Online() {
+		if username == "member" {
+			t.Error("the old nickname is still online")
+		}
+	}
+	found := chat.Find(func(c *hub.Client) bool { return c.Username() == "renamed" })
+	if len(found) != 1 {
+		t.Errorf("%d connections under the new nickname, want 1", len(found))
+	}
+}
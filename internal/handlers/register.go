@@ -1,13 +1,18 @@
 package handlers
 
 import (
-	"db"
 	"encoding/json"
+	"fmt"
 	"middlewares"
 	"models"
 	"net/http"
+	"strings"
+	"validation"
 )
 
+// maxFormSize caps the body of the forms and posts, far above what they need
+const maxFormSize = 64 << 10
+
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	// Ensuring the method is POST
 	if r.Method != http.MethodPost {
@@ -17,16 +22,31 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Getting the JSON form data to test
 	var req models.RegisterRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormSize)).Decode(&req)
 	if err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
 
-	uuid := middlewares.GenerateSessionID()
+	// Refuse the whole form with every problem found
+	var v validation.Validator
+	v.Name("firstname", "First name", req.Firstname)
+	v.Name("lastname", "Last name", req.Lastname)
+	v.Nickname("username", req.Username)
+	v.Email("email", req.Email)
+	v.Gender("gender", req.Gender)
+	v.Password("password", req.Password)
+	if !v.Valid() {
+		writeValidationErrors(w, v.Errors)
+		return
+	}
+
+	uuid := middlewares.GenerateUUID()
 
 	// Inserting the user into the database
-	userID, errorMsg := db.UserInsert(uuid, req.Username, req.Gender, req.Firstname, req.Lastname, req.Email, req.Password, "User", 1)
+	userID, errorMsg := store.UserInsert(uuid, req.Username, req.Gender, req.Firstname, req.Lastname, req.Email, req.Password, middlewares.RoleUser, 1)
 
 	// Checking if the insert failed
 	if userID == 0 {
@@ -36,9 +56,37 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account works right away, the email address is verified later
+	if allowed, err := verificationEmailAllowed(userID, req.Email); err != nil {
+		fmt.Println("Error recording email verification:", err)
+	} else if !allowed {
+		fmt.Println("Too many verification emails for", req.Email)
+	} else if err := sendEmailVerification(userID); err != nil {
+		fmt.Println("Error sending email verification:", err)
+	}
+
 	// Maintenant que l'utilisateur est enregistré, créer une session
-	middlewares.CreateSession(w, userID, req.Username, "User", uuid)
+	if err := middlewares.CreateSession(w, r, userID); err != nil {
+		fmt.Println("Error creating session:", err)
+		json.NewEncoder(w).Encode(models.RegisterResponse{Success: false, Message: "Registration succeeded but login failed, please log in"})
+		return
+	}
 
 	// If the insert didn't fail, notify the js of the success
-	json.NewEncoder(w).Encode(models.RegisterResponse{Success: true, Message: "Registration successful"})
+	json.NewEncoder(w).Encode(models.RegisterResponse{
+		Success: true,
+		Message: "Registration successful, check your email to verify your address",
+	})
+}
+
+// writeValidationErrors answers a form refused by validation with a 422 and
+// the problem of each field
+func writeValidationErrors(w http.ResponseWriter, errors []models.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(models.RegisterResponse{
+		Success: false,
+		Message: "Please correct the highlighted fields",
+		Errors:  errors,
+	})
 }
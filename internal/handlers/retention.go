@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"config"
+	"fmt"
+	"time"
+)
+
+// runRetention purges what was deleted more than config.DELETED_RETENTION
+// ago, every config.RETENTION_PURGE_INTERVAL, forever
+func runRetention() {
+	ticker := time.NewTicker(config.RETENTION_PURGE_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := store.PurgeDeleted(time.Now().Add(-config.DELETED_RETENTION))
+		if err != nil {
+			fmt.Println("Error purging deleted content:", err)
+			continue
+		}
+		if purged > 0 {
+			fmt.Printf("Purged %d deleted row(s)\n", purged)
+		}
+	}
+}
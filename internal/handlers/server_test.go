@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"db"
+	"db/migrations"
+	"io"
+	"mailer"
+	"middlewares"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// setupServer serves the API, with the routes and middlewares of
+// cmd/golang-server-layout, on a fresh in-memory database
+func setupServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	s, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := migrations.Up(s.DB); err != nil {
+		t.Fatal(err)
+	}
+	mail, err := mailer.NewLogMailer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	middlewares.Init(s)
+	Init(s, mail)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", LoginHandler)
+	mux.HandleFunc("POST /api/password-reset", HandlePasswordResetRequest)
+	mux.HandleFunc("/ws", middlewares.RequireAuth(HandleConnection))
+
+	mux.HandleFunc("/api/users", GetConnectedAndDisconnectedUsers)
+	mux.HandleFunc("/api/posts", HandleFetchPosts)
+	mux.HandleFunc("/api/postCreation", middlewares.RequireVerified(HandleCreatePost))
+	mux.HandleFunc("GET /api/categories", HandleFetchCategories)
+
+	mux.HandleFunc("PUT /api/posts/{id}", middlewares.RequireAuth(HandleUpdatePost))
+	mux.HandleFunc("PATCH /api/posts/{id}", middlewares.RequireAuth(HandleUpdatePost))
+	mux.HandleFunc("PUT /api/posts/{id}/comments/{cid}", middlewares.RequireAuth(HandleUpdateComment))
+	mux.HandleFunc("PATCH /api/comments/{id}", middlewares.RequireAuth(HandleUpdateComment))
+	mux.HandleFunc("DELETE /api/users/{id}", middlewares.RequireAuth(HandleDeleteUser))
+
+	mux.HandleFunc("GET /api/posts/{id}/comments", FetchPostCommentsHandler)
+	mux.HandleFunc("POST /api/posts/{id}/comments", middlewares.RequireVerified(CreateCommentHandler))
+	mux.HandleFunc("/api/posts/", http.NotFound)
+
+	mux.HandleFunc("/logout", middlewares.OptionalAuth(LogOutHandler))
+	mux.HandleFunc("GET /api/sessions", middlewares.RequireAuth(HandleListSessions))
+	mux.HandleFunc("DELETE /api/sessions", middlewares.RequireAuth(HandleRevokeAllSessions))
+	mux.HandleFunc("DELETE /api/sessions/{id}", middlewares.RequireAuth(HandleRevokeSession))
+
+	mux.HandleFunc("PATCH /api/me", middlewares.RequireAuth(HandleUpdateProfile))
+	mux.HandleFunc("POST /api/me/password", middlewares.RequireAuth(HandleChangePassword))
+	mux.HandleFunc("POST /api/verify-email", HandleVerifyEmail)
+	mux.HandleFunc("POST /api/verify-email/resend", middlewares.RequireAuth(HandleResendVerification))
+
+	server := httptest.NewServer(WithErrorHandling(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// loggedIn creates a user with a session and returns its ID and token
+func loggedIn(t *testing.T, nickname, role string) (int, string) {
+	t.Helper()
+
+	id, msg := store.UserInsert(middlewares.GenerateUUID(), nickname, "Other", "First", "Last",
+		nickname+"@example.com", "Passw0rd!", role, 0)
+	if id == 0 {
+		t.Fatalf("creating %s: %s", nickname, msg)
+	}
+	return id, newSession(t, id)
+}
+
+// newSession logs a user in once more and returns the session token
+func newSession(t *testing.T, userID int) string {
+	t.Helper()
+
+	token := middlewares.GenerateSessionID()
+	if err := middlewares.StoreSession(token, userID, time.Now().Add(time.Hour), "test", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// call sends a request with the given session, if any, and returns the
+// status and body of the answer
+func call(t *testing.T, server *httptest.Server, method, path, token, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Cookie", "session_id="+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// dial opens a WebSocket with a session, the way the frontend does
+func dial(t *testing.T, server *httptest.Server, token string) *websocket.Conn {
+	t.Helper()
+
+	header := http.Header{}
+	header.Set("Origin", "http://localhost:8080")
+	header.Set("Cookie", "session_id="+token)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// closedWith reads a socket until it is closed and reports whether it was
+// with the given code. Frames sent before the close are skipped.
+func closedWith(t *testing.T, conn *websocket.Conn, code int) bool {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, code) {
+				t.Logf("socket ended with %v", err)
+				return false
+			}
+			return true
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hub"
+	"middlewares"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// HandleListSessions lists the devices the session user is logged in on:
+// GET /api/sessions
+func HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	sessions, err := store.SessionSelectByUserID(user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error fetching sessions")
+		return
+	}
+
+	// Struct to represent a session (the token itself is never shown)
+	type Session struct {
+		ID        int       `json:"id"`
+		UserAgent string    `json:"user_agent"`
+		IP        string    `json:"ip"`
+		CreatedAt time.Time `json:"created_at"`
+		LastSeen  time.Time `json:"last_seen"`
+		ExpiresAt time.Time `json:"expires_at"`
+		Current   bool      `json:"current"` // The session this request was made with
+	}
+
+	response := []Session{}
+	for _, s := range sessions {
+		response = append(response, Session{
+			ID: s.ID, UserAgent: s.UserAgent, IP: s.IP, CreatedAt: s.CreatedAt,
+			LastSeen: s.LastSeen, ExpiresAt: s.ExpiresAt, Current: s.ID == user.SessionID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"sessions": response})
+}
+
+// HandleRevokeSession logs one of the session user's devices out:
+// DELETE /api/sessions/{id}
+func HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	sessionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	// Only the user's own sessions can be found this way
+	deleted, err := store.SessionDelete(user.ID, sessionID)
+	if err != nil {
+		fmt.Println("Error revoking session:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error revoking session")
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	closeSessionSockets("session_revoked", sessionID)
+	markLoggedOutIfNoSession(user.ID)
+
+	if sessionID == user.SessionID {
+		middlewares.ClearSessionCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRevokeAllSessions logs the session user out everywhere, this
+// device included: DELETE /api/sessions
+func HandleRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+
+	if err := revokeUserSessions(user.ID); err != nil {
+		fmt.Println("Error revoking sessions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error revoking sessions")
+		return
+	}
+
+	middlewares.ClearSessionCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeUserSessions logs a user out of every device and closes their
+// WebSockets
+func revokeUserSessions(userID int) error {
+	sessions, err := store.SessionSelectByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if err := store.SessionDeleteByUserID(userID); err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	closeSessionSockets("session_revoked", ids...)
+
+	if err := store.UserSetConnected(userID, 0); err != nil {
+		fmt.Println("Error logging out:", err)
+	}
+	return nil
+}
+
+// markLoggedOutIfNoSession clears the connected flag of a user once the last
+// of their sessions is gone
+func markLoggedOutIfNoSession(userID int) {
+	sessions, err := store.SessionSelectByUserID(userID)
+	if err != nil {
+		fmt.Println("Error fetching sessions:", err)
+		return
+	}
+	if len(sessions) > 0 {
+		return
+	}
+	if err := store.UserSetConnected(userID, 0); err != nil {
+		fmt.Println("Error logging out:", err)
+	}
+}
+
+// closeSessionSockets disconnects the WebSockets opened by the given
+// sessions, telling why; their pages go back to the login screen
+func closeSessionSockets(reason string, sessionIDs ...int) {
+	revoked := make(map[int]bool)
+	for _, id := range sessionIDs {
+		revoked[id] = true
+	}
+
+	clients := chat.Find(func(c *hub.Client) bool {
+		return revoked[c.SessionID]
+	})
+	for _, c := range clients {
+		c.Close(websocket.ClosePolicyViolation, reason)
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"middlewares"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// sessionID returns the database ID of the session a token belongs to
+func sessionID(t *testing.T, token string) int {
+	t.Helper()
+
+	session, err := middlewares.LookupSession(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session.ID
+}
+
+// isConnected reports whether a user is listed as connected
+func isConnected(t *testing.T, userID int) bool {
+	t.Helper()
+
+	users, err := store.UserSelectByConnected(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		if u.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogOutKeepsTheOtherDevicesConnected(t *testing.T) {
+	server := setupServer(t)
+	userID, laptop := loggedIn(t, "alice", middlewares.RoleUser)
+	phone := newSession(t, userID)
+	if err := store.UserSetConnected(userID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	call(t, server, http.MethodGet, "/logout", laptop, "")
+	if _, err := middlewares.LookupSession(laptop); err == nil {
+		t.Error("the session logged out of still works")
+	}
+	if !isConnected(t, userID) {
+		t.Error("logging out of one device disconnected the user")
+	}
+
+	call(t, server, http.MethodGet, "/logout", phone, "")
+	if isConnected(t, userID) {
+		t.Error("the user is still connected after logging out everywhere")
+	}
+}
+
+func TestListSessionsMarksTheCurrentOne(t *testing.T) {
+	server := setupServer(t)
+	userID, laptop := loggedIn(t, "alice", middlewares.RoleUser)
+	phone := newSession(t, userID)
+	loggedIn(t, "bob", middlewares.RoleUser)
+
+	status, body := call(t, server, http.MethodGet, "/api/sessions", laptop, "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	if strings.Contains(body, laptop) || strings.Contains(body, phone) {
+		t.Error("the session list shows tokens")
+	}
+	var answer struct {
+		Sessions []struct {
+			ID      int  `json:"id"`
+			Current bool `json:"current"`
+		} `json:"sessions"`
+	}
+	if err := json.Unmarshal([]byte(body), &answer); err != nil {
+		t.Fatal(err)
+	}
+	if len(answer.Sessions) != 2 {
+		t.Fatalf("listed %d sessions, want alice's 2", len(answer.Sessions))
+	}
+	for _, s := range answer.Sessions {
+		if s.Current != (s.ID == sessionID(t, laptop)) {
+			t.Errorf("session %d: current = %v", s.ID, s.Current)
+		}
+	}
+}
+
+func TestRevokeSessionClosesItsSockets(t *testing.T) {
+	server := setupServer(t)
+	userID, laptop := loggedIn(t, "alice", middlewares.RoleUser)
+	phone := newSession(t, userID)
+	_, bob := loggedIn(t, "bob", middlewares.RoleUser)
+	phoneSocket := dial(t, server, phone)
+	phoneID := sessionID(t, phone)
+
+	path := "/api/sessions/" + strconv.Itoa(phoneID)
+	if status, _ := call(t, server, http.MethodDelete, path, bob, ""); status != http.StatusNotFound {
+		t.Errorf("revoking someone else's session: status %d, want 404", status)
+	}
+	if status, body := call(t, server, http.MethodDelete, path, laptop, ""); status != http.StatusNoContent {
+		t.Fatalf("status %d: %s", status, body)
+	}
+
+	if !closedWith(t, phoneSocket, websocket.ClosePolicyViolation) {
+		t.Error("the revoked session's socket wasn't closed with 1008")
+	}
+	if _, err := middlewares.LookupSession(phone); err == nil {
+		t.Error("the revoked session still works")
+	}
+	if _, err := middlewares.LookupSession(laptop); err != nil {
+		t.Errorf("the session that revoked the other one stopped working: %v", err)
+	}
+}
+
+func TestRevokeAllSessionsClosesEverySocket(t *testing.T) {
+	server := setupServer(t)
+	userID, laptop := loggedIn(t, "alice", middlewares.RoleUser)
+	phone := newSession(t, userID)
+	laptopSocket := dial(t, server, laptop)
+	phoneSocket := dial(t, server, phone)
+
+	if status, body := call(t, server, http.MethodDelete, "/api/sessions", laptop, ""); status != http.StatusNoContent {
+		t.Fatalf("status %d: %s", status, body)
+	}
+
+	for name, conn := range map[string]*websocket.Conn{"laptop": laptopSocket, "phone": phoneSocket} {
+		if !closedWith(t, conn, websocket.ClosePolicyViolation) {
+			t.Errorf("the %s socket wasn't closed with 1008", name)
+		}
+	}
+	for _, token := range []string{laptop, phone} {
+		if _, err := middlewares.LookupSession(token); err == nil {
+			t.Error("a session survived logging out everywhere")
+		}
+	}
+	if isConnected(t, userID) {
+		t.Error("the user is still connected after logging out everywhere")
+	}
+}
@@ -1,9 +1,8 @@
 package handlers
 
 import (
-	"db"
 	"encoding/json"
-	"fmt"
+	"middlewares"
 	"models"
 	"net/http"
 )
@@ -16,11 +15,9 @@ func NavbarHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Retrieving the username with the uuid stored in the cookie
-	var username string
-	cookie, errCookie := r.Cookie("session_id")
-	if errCookie != nil {
-		fmt.Println("Error retrieving cookie in navbarhandler: ", errCookie)
+	// Retrieving the username of the session stored in the cookie
+	user, ok := middlewares.CurrentUser(r)
+	if !ok {
 		// Return a response with empty username or some default state
 		response := models.Response{
 			Username: "", // or "Guest" or whatever makes sense for your application
@@ -28,15 +25,15 @@ func NavbarHandler(w http.ResponseWriter, r *http.Request) {
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
-		return // Important: return here to avoid using cookie.Value when cookie is nil
+		return
 	}
 
-	// Only try to get the username if we have a valid cookie
-	username = db.UserNicknameWithUUID(cookie.Value)
-
 	// Create the response
 	response := models.Response{
-		Username: username,
+		UserID:        user.ID,
+		Username:      user.Username,
+		EmailVerified: user.EmailVerified,
+		CanModerate:   user.Can(middlewares.ModerateContent),
 	}
 
 	// Set the content type header
@@ -1,36 +1,24 @@
 package handlers
 
 import (
-	"db"
 	"encoding/json"
 	"net/http"
 )
 
 func GetConnectedAndDisconnectedUsers(w http.ResponseWriter, r *http.Request) {
-	db := db.SetupDatabase()
-	defer db.Close()
-
 	// Query for connected users (connected = 1)
-	connectedUsersQuery := `SELECT id, nickName, gender, firstName, lastName, email, role 
-	                      FROM "user" WHERE connected = 1`
-
-	connectedRows, err := db.Query(connectedUsersQuery)
+	connected, err := store.UserSelectByConnected(1)
 	if err != nil {
 		http.Error(w, "Failed to query connected users: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer connectedRows.Close()
 
 	// Query for disconnected users (connected = 0)
-	disconnectedUsersQuery := `SELECT id, nickName, gender, firstName, lastName, email, role 
-	                         FROM "user" WHERE connected = 0`
-
-	disconnectedRows, err := db.Query(disconnectedUsersQuery)
+	disconnected, err := store.UserSelectByConnected(0)
 	if err != nil {
 		http.Error(w, "Failed to query disconnected users: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer disconnectedRows.Close()
 
 	// Struct to represent a user (excluding password for security)
 	type User struct {
@@ -52,30 +40,20 @@ func GetConnectedAndDisconnectedUsers(w http.ResponseWriter, r *http.Request) {
 
 	// Parse connected users
 	connectedUsers := []User{}
-	for connectedRows.Next() {
-		var user User
-		user.Connected = 1
-		err := connectedRows.Scan(&user.ID, &user.NickName, &user.Gender, &user.FirstName,
-			&user.LastName, &user.Email, &user.Role)
-		if err != nil {
-			http.Error(w, "Error scanning connected users: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		connectedUsers = append(connectedUsers, user)
+	for _, u := range connected {
+		connectedUsers = append(connectedUsers, User{
+			ID: u.ID, NickName: u.NickName, Gender: u.Gender, FirstName: u.FirstName,
+			LastName: u.LastName, Email: u.Email, Role: u.Role, Connected: 1,
+		})
 	}
 
 	// Parse disconnected users
 	disconnectedUsers := []User{}
-	for disconnectedRows.Next() {
-		var user User
-		user.Connected = 0
-		err := disconnectedRows.Scan(&user.ID, &user.NickName, &user.Gender, &user.FirstName,
-			&user.LastName, &user.Email, &user.Role)
-		if err != nil {
-			http.Error(w, "Error scanning disconnected users: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		disconnectedUsers = append(disconnectedUsers, user)
+	for _, u := range disconnected {
+		disconnectedUsers = append(disconnectedUsers, User{
+			ID: u.ID, NickName: u.NickName, Gender: u.Gender, FirstName: u.FirstName,
+			LastName: u.LastName, Email: u.Email, Role: u.Role, Connected: 0,
+		})
 	}
 
 	// Create the response
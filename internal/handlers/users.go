@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"db"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -16,7 +15,7 @@ func HandleUserSelectAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := db.UserSelectAll()
+	users, err := store.UserSelectAll()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -40,7 +39,7 @@ func HandleGetUserById(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, _ := strconv.Atoi(vars["id"])
 
-	user, err := db.UserSelectByID(userID)
+	user, err := store.UserSelectByID(userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"config"
+	"encoding/json"
+	"fmt"
+	"middlewares"
+	"models"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// verificationEmailAllowed records a verification email about to be sent to
+// an address for a user, and reports whether the limits of
+// config.EMAIL_VERIFICATION_WINDOW let it go
+func verificationEmailAllowed(userID int, email string) (bool, error) {
+	since := time.Now().Add(-config.EMAIL_VERIFICATION_WINDOW)
+	return store.EmailVerificationRequestInsert(userID, email, since,
+		config.EMAIL_VERIFICATION_MAX_REQUESTS, config.EMAIL_VERIFICATION_ADDRESS_MAX_REQUESTS)
+}
+
+// sendEmailVerification issues a new verification token for the current
+// email address of a user and emails the link
+func sendEmailVerification(userID int) error {
+	user, err := store.UserSelectByID(userID)
+	if err != nil {
+		return err
+	}
+
+	token := middlewares.GenerateToken()
+	expiresAt := time.Now().Add(config.EMAIL_VERIFICATION_LIFETIME)
+	if err := store.EmailVerificationInsert(user.ID, user.Email, middlewares.HashToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	link := config.APP_URL + "/?verify_token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Please confirm that this is your email address by opening this link:\n\n"+
+		"%s\n\n"+
+		"The link expires in %s. If you didn't create an account, you can ignore this email.\n",
+		user.NickName, link, config.EMAIL_VERIFICATION_LIFETIME)
+	return mail.Send(user.Email, "Verify your email address", body)
+}
+
+// HandleVerifyEmail marks an email address as verified from the token of
+// the emailed link: POST /api/verify-email
+func HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if _, err := store.EmailVerificationConsume(middlewares.HashToken(req.Token)); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "This verification link is invalid or has expired")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RegisterResponse{Success: true, Message: "Your email address is verified"})
+}
+
+// HandleResendVerification sends the session user a new verification link:
+// POST /api/verify-email/resend. Only a few can be sent per
+// config.EMAIL_VERIFICATION_WINDOW.
+func HandleResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, _ := middlewares.CurrentUser(r)
+	if user.EmailVerified {
+		writeJSONError(w, http.StatusConflict, "Your email address is already verified")
+		return
+	}
+
+	current, err := store.UserSelectByID(user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	allowed, err := verificationEmailAllowed(user.ID, current.Email)
+	if err != nil {
+		fmt.Println("Error recording email verification:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error sending the verification email")
+		return
+	}
+	if !allowed {
+		writeJSONError(w, http.StatusTooManyRequests, "Too many verification emails, try again later")
+		return
+	}
+
+	if err := sendEmailVerification(user.ID); err != nil {
+		fmt.Println("Error sending email verification:", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error sending the verification email")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RegisterResponse{Success: true, Message: "A new verification link has been sent to your email address"})
+}
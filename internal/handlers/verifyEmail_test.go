@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"config"
+	"middlewares"
+	"net/http"
+	"testing"
+)
+
+func TestVerificationEmailsAreLimited(t *testing.T) {
+	server := setupServer(t)
+	userID, token := loggedIn(t, "member", middlewares.RoleUser)
+
+	limit := config.EMAIL_VERIFICATION_MAX_REQUESTS
+	config.EMAIL_VERIFICATION_MAX_REQUESTS = 2
+	t.Cleanup(func() { config.EMAIL_VERIFICATION_MAX_REQUESTS = limit })
+
+	for i := 1; i <= 2; i++ {
+		if status, body := call(t, server, http.MethodPost, "/api/verify-email/resend", token, ""); status != http.StatusOK {
+			t.Fatalf("resend %d: status %d: %s", i, status, body)
+		}
+	}
+	if status, _ := call(t, server, http.MethodPost, "/api/verify-email/resend", token, ""); status != http.StatusTooManyRequests {
+		t.Errorf("resend past the limit: status %d, want %d", status, http.StatusTooManyRequests)
+	}
+
+	// Changing the address sends one more, so it is refused too, and the
+	// address stays as it was
+	if status, _ := call(t, server, http.MethodPatch, "/api/me", token, `{"email": "new@example.com"}`); status != http.StatusTooManyRequests {
+		t.Errorf("email change past the limit: status %d, want %d", status, http.StatusTooManyRequests)
+	}
+	user, err := store.UserSelectByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "member@example.com" {
+		t.Errorf("email changed to %s", user.Email)
+	}
+}
+
+func TestUnverifiedUsersMayNotPublishWhenRequired(t *testing.T) {
+	server := setupServer(t)
+	loggedIn(t, "friend", middlewares.RoleUser)
+	_, token := loggedIn(t, "member", middlewares.RoleUser)
+
+	required := config.REQUIRE_VERIFIED_EMAIL
+	config.REQUIRE_VERIFIED_EMAIL = true
+	t.Cleanup(func() { config.REQUIRE_VERIFIED_EMAIL = required })
+
+	post := `{"title": "Hello", "content": "First post", "categories": ["General"]}`
+	if status, body := call(t, server, http.MethodPost, "/api/postCreation", token, post); status != http.StatusForbidden {
+		t.Errorf("post of an unverified user: status %d: %s", status, body)
+	}
+
+	conn := dial(t, server, token)
+	if err := conn.WriteJSON(map[string]string{"type": "private_message", "receiver": "friend", "message": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if frame := frameOfType(t, conn, "error"); frame.Message == "" {
+		t.Error("the refused message came without a reason")
+	}
+}
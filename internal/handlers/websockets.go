@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"db"
 	"encoding/json"
 	"fmt"
+	"hub"
+	"middlewares"
 	"models"
 	"net/http"
 	"os"
@@ -12,9 +13,6 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-var clients = models.GetClientMap()
-var mu = models.GetMux()
-
 // Upgrader to upgrade the HTTP connection to a WebSocket connection
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
@@ -52,103 +50,154 @@ func HandleConnection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Regular WebSocket handling code for local development
-	// Retrieving the cookie for the uuid
-	cookie, errCookie := r.Cookie("session_id")
-	if errCookie != nil {
-		fmt.Println("Error getting cookie:", errCookie)
-		return
-	}
-	// Getting the username with the UUID stored in the cookie
-	username := db.UserNicknameWithUUID(cookie.Value)
+	// The socket belongs to whoever owns the session, whatever the frames claim
+	user, _ := middlewares.CurrentUser(r)
+	username := user.Username
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		fmt.Println("Error upgrading:", err)
 		return
 	}
-	defer conn.Close()
 
-	mu.Lock()
-	clients[username] = conn
-	mu.Unlock()
-	SendUserListToAll()
+	// From now on only the client's writer goroutine touches conn for writing
+	client := hub.NewClient(conn, username, user.SessionID)
+	chat.Register(client)
+	go client.WritePump()
+	defer chat.Unregister(client)
 
 	fmt.Println(username, "connected")
 
-	for {
-		_, msg, err := conn.ReadMessage()
-		if err != nil {
-			fmt.Println(username, "disconnected")
-			mu.Lock()
-			delete(clients, username)
-			mu.Unlock()
-			SendUserListToAll()
-			break
+	// Hand over whatever arrived while the user was away
+	sendPendingMessages(client)
+
+	// Blocks until the browser goes away or stops answering pings
+	err = client.ReadPump(func(msg []byte) {
+		handleFrame(client, msg)
+	})
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+		fmt.Println(username, "connection lost:", err)
+	}
+	fmt.Println(username, "disconnected")
+}
+
+// handleFrame dispatches one frame received from a client
+func handleFrame(client *hub.Client, msg []byte) {
+	// Logging out (or the session going away) ends the socket too
+	session, err := middlewares.LookupSessionByID(client.SessionID)
+	if err != nil {
+		fmt.Println("Closing socket of", client.Username(), ":", err)
+		client.Close(websocket.ClosePolicyViolation, err.Error())
+		return
+	}
+
+	var receivedMsg models.PrivateMessage
+	err = json.Unmarshal(msg, &receivedMsg)
+	if err != nil {
+		fmt.Println("Invalid JSON:", err)
+		return
+	}
+
+	// Frames always speak for the socket's owner
+	if receivedMsg.Sender != "" && receivedMsg.Sender != client.Username() {
+		fmt.Println("Rejected frame from", client.Username(), "claiming to be", receivedMsg.Sender)
+		rejectFrame(client, "sender does not match your session")
+		return
+	}
+	receivedMsg.Sender = client.Username()
+
+	// Get the sender and receiver IDs
+	sender := store.UserIDWithNickname(receivedMsg.Sender)
+	receiver := store.UserIDWithNickname(receivedMsg.Receiver)
+
+	// Check the type of message
+	if receivedMsg.Type == "private_message" {
+		if !middlewares.MayPublish(session.EmailVerified) {
+			rejectFrame(client, "please verify your email address before sending messages")
+			return
 		}
+		fmt.Println("Received private message from", receivedMsg.Sender, "to", receivedMsg.Receiver)
 
-		var receivedMsg models.PrivateMessage
-		err = json.Unmarshal(msg, &receivedMsg)
+		// Insert the message into the database, it stays pending until the
+		// receiver acknowledges it
+		id, err := store.PrivateMessageInsert(sender, receiver, receivedMsg.Message)
 		if err != nil {
-			fmt.Println("Invalid JSON:", err)
-			continue
+			fmt.Println("Error storing private message:", err)
+			rejectFrame(client, "message could not be sent")
+			return
 		}
+		receivedMsg.ID = id
 
-		// Get the sender and receiver IDs
-		sender := db.UserIDWithNickname(receivedMsg.Sender)
-		receiver := db.UserIDWithNickname(receivedMsg.Receiver)
+		// Send the message to the receiver, client side
+		sendPrivateMessage(receivedMsg)
 
-		// Check the type of message
-		if receivedMsg.Type == "private_message" {
-			fmt.Println("Received private message from", receivedMsg.Sender, "to", receivedMsg.Receiver)
+		// Both participants' conversation lists now start with each other
+		sendConversationUpdate(receivedMsg.Sender, receivedMsg.Receiver)
+		sendConversationUpdate(receivedMsg.Receiver, receivedMsg.Sender)
 
-			// Send the message to the receiver, client side
-			db.SendPrivateMessage(receivedMsg)
+	} else if receivedMsg.Type == "mark_read" {
+		// The user read the conversation with Receiver up to message ID
+		count, err := store.PrivateMessageMarkRead(sender, receiver, receivedMsg.ID)
+		if err != nil {
+			fmt.Println("Error marking messages as read:", err)
+			return
+		}
+		if count > 0 {
+			sendReadReceipt(client.Username(), receivedMsg.Receiver, receivedMsg.ID)
+			// Other tabs of the reader drop their unread count
+			sendConversationUpdate(client.Username(), receivedMsg.Receiver)
+		}
 
-			// Insert the message into the database
-			db.PrivateMessageInsert(sender, receiver, receivedMsg.Message)
+	} else if receivedMsg.Type == "message_ack" {
+		// The receiver got these messages, stop holding them for later
+		if err := store.PrivateMessageMarkDelivered(sender, receivedMsg.MessageIDs); err != nil {
+			fmt.Println("Error marking messages as delivered:", err)
+		}
+
+	} else if receivedMsg.Type == "chat_history_request" {
+		fmt.Println("Received chat history request between", receivedMsg.Sender, "to", receivedMsg.Receiver)
+		history, err := store.ChatHistory(sender, receiver, receivedMsg.Before, receivedMsg.Limit)
+		if err != nil {
+			fmt.Println("Error loading chat history:", err)
+			return
+		}
+		jsonHistory, err := json.Marshal(history)
+		if err != nil {
+			fmt.Println("Error marshaling chat history:", err)
+			return
+		}
+		chat.Reply(client, jsonHistory)
 
-		} else if receivedMsg.Type == "chat_history_request" {
-			fmt.Println("Received chat history request between", receivedMsg.Sender, "to", receivedMsg.Receiver)
-			db.SendChatHistory(sender, receiver, conn)
+	} else if receivedMsg.Type == "typing" {
+		typingInProgress(receivedMsg)
 
-		} else if receivedMsg.Type == "typing" {
-			db.TypingInProgress(receivedMsg)
+	} else if receivedMsg.Type == "subscribe" || receivedMsg.Type == "unsubscribe" {
+		// The comments of the posts the page has open
+		topics := make([]string, 0, len(receivedMsg.PostIDs))
+		for _, postID := range receivedMsg.PostIDs {
+			topics = append(topics, postTopic(postID))
+		}
+		if receivedMsg.Type == "unsubscribe" {
+			chat.Unsubscribe(client, topics...)
+		} else if !chat.Subscribe(client, topics...) {
+			rejectFrame(client, "too many open posts, close some to follow new ones")
 		}
 	}
 }
 
-// SendUserListToAll sends the current list of connected users to all connected clients
-func SendUserListToAll() {
-	mu.Lock()
-	// Create a slice of all connected usernames
-	userList := make([]string, 0, len(clients))
-	for username := range clients {
-		userList = append(userList, username)
-	}
-
-	// Prepare the message once
+// rejectFrame tells a client why the frame it sent was ignored
+func rejectFrame(client *hub.Client, reason string) {
 	response := models.PrivateMessage{
-		Type:     "user_list",
-		Sender:   "server",
-		Message:  "",
-		UserList: userList,
+		Type:     "error",
+		Sender:   "system",
+		Receiver: client.Username(),
+		Message:  reason,
 	}
 
-	// Convert to JSON
 	jsonResponse, err := json.Marshal(response)
 	if err != nil {
-		fmt.Println("Error marshaling user list:", err)
-		mu.Unlock()
+		fmt.Println("Error marshaling JSON:", err)
 		return
 	}
-
-	// Send to all connected clients
-	for username, conn := range clients {
-		response.Receiver = username // Set the receiver for logging purposes
-		err := conn.WriteMessage(websocket.TextMessage, jsonResponse)
-		if err != nil {
-			fmt.Println("Error sending user list to", username, ":", err)
-		}
-	}
-	mu.Unlock()
+	chat.Reply(client, jsonResponse)
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"middlewares"
+	"models"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// frameOfType reads a socket until a frame of the given type arrives
+func frameOfType(t *testing.T, conn *websocket.Conn, kind string) models.PrivateMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for a %s frame: %v", kind, err)
+		}
+		var frame models.PrivateMessage
+		if err := json.Unmarshal(data, &frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type == kind {
+			return frame
+		}
+	}
+}
+
+func TestFramesMayNotSpeakForSomebodyElse(t *testing.T) {
+	server := setupServer(t)
+	loggedIn(t, "victim", middlewares.RoleUser)
+	loggedIn(t, "friend", middlewares.RoleUser)
+	_, token := loggedIn(t, "member", middlewares.RoleUser)
+	conn := dial(t, server, token)
+
+	err := conn.WriteJSON(map[string]string{"type": "private_message", "sender": "victim", "receiver": "friend", "message": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame := frameOfType(t, conn, "error"); frame.Message == "" {
+		t.Error("the refused frame came without a reason")
+	}
+
+	history, err := store.ChatHistory(store.UserIDWithNickname("victim"), store.UserIDWithNickname("friend"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Messages) != 0 {
+		t.Errorf("%d messages stored under the victim's name", len(history.Messages))
+	}
+}
+
+func TestFramesAfterLogoutCloseTheSocket(t *testing.T) {
+	server := setupServer(t)
+	userID, token := loggedIn(t, "member", middlewares.RoleUser)
+	conn := dial(t, server, token)
+	frameOfType(t, conn, "user_list")
+
+	// The session goes away without the socket being told, like an expiry
+	if _, err := store.SessionDelete(userID, sessionID(t, token)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.WriteJSON(map[string]string{"type": "typing", "receiver": "member"}); err != nil {
+		t.Fatal(err)
+	}
+	if !closedWith(t, conn, websocket.ClosePolicyViolation) {
+		t.Error("the socket of a deleted session wasn't closed with 1008")
+	}
+}
@@ -1 +1,246 @@
 package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hub"
+	"models"
+	"strconv"
+)
+
+// sendPrivateMessage delivers a private message to the receiver through the hub
+func sendPrivateMessage(msg models.PrivateMessage) {
+	// Ensure both sender and receiver are set
+	if msg.Sender == "" || msg.Receiver == "" {
+		fmt.Println("Error: Sender or receiver not specified")
+		return
+	}
+
+	// Create a response message
+	response := models.PrivateMessage{
+		ID:       msg.ID,
+		Type:     "private_message",
+		Sender:   msg.Sender,
+		Receiver: msg.Receiver,
+		Message:  msg.Message,
+	}
+
+	// Convert response to JSON
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+
+	// Send message to receiver if they're connected
+	if !chat.SendTo(msg.Receiver, jsonResponse) {
+		// Notify sender that receiver is offline
+		notifyMsg := models.PrivateMessage{
+			Type:     "system_notification",
+			Sender:   "system",
+			Receiver: msg.Sender,
+			Message:  msg.Receiver + " is currently offline. Message will be delivered when they connect.",
+		}
+
+		notifyJson, _ := json.Marshal(notifyMsg)
+		chat.SendTo(msg.Sender, notifyJson)
+	}
+
+	// Also send a copy/confirmation to the sender
+	confirmMsg := models.PrivateMessage{
+		ID:       msg.ID,
+		Type:     "message_sent",
+		Sender:   msg.Sender,
+		Receiver: msg.Receiver,
+		Message:  msg.Message,
+	}
+
+	confirmJson, _ := json.Marshal(confirmMsg)
+	chat.SendTo(msg.Sender, confirmJson)
+
+	// Log the message
+	fmt.Printf("Private message from %s to %s: %s\n", msg.Sender, msg.Receiver, msg.Message)
+}
+
+// sendPendingMessages pushes the messages a user received while offline.
+// They are marked delivered once the client acknowledges them with a
+// message_ack frame. The conversation list tells what is still unread.
+func sendPendingMessages(client *hub.Client) {
+	userID := store.UserIDWithNickname(client.Username())
+
+	messages, err := store.PrivateMessageSelectPending(userID)
+	if err != nil {
+		fmt.Println("Error loading pending messages:", err)
+		return
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	response := models.PendingMessages{
+		Type:     "pending_messages",
+		Messages: messages,
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+	chat.Reply(client, jsonResponse)
+}
+
+// sendReadReceipt tells the original sender that reader has seen their
+// messages up to messageID
+func sendReadReceipt(reader, sender string, messageID int) {
+	response := models.PrivateMessage{
+		ID:       messageID,
+		Type:     "read_receipt",
+		Sender:   reader,
+		Receiver: sender,
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+	chat.SendTo(sender, jsonResponse)
+}
+
+// sendConversationUpdate pushes the up to date conversation list entry of
+// username with partner, after a message was sent or read
+func sendConversationUpdate(username, partner string) {
+	userID := store.UserIDWithNickname(username)
+	partnerID := store.UserIDWithNickname(partner)
+
+	conversation, err := store.Conversation(userID, partnerID)
+	if err != nil {
+		fmt.Println("Error loading conversation:", err)
+		return
+	}
+	conversation.Type = "conversation_update"
+	conversation.Online = onlineUsers()[partner]
+
+	jsonResponse, err := json.Marshal(conversation)
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+	chat.SendTo(username, jsonResponse)
+}
+
+// sendMessageChange tells both sides of a conversation that a message was
+// deleted (message_deleted) or restored (message_restored), with what to show
+// in its place, and refreshes their conversation lists
+func sendMessageChange(eventType string, messageID int, sender, receiver, message string) {
+	response := models.PrivateMessage{
+		ID:       messageID,
+		Type:     eventType,
+		Sender:   sender,
+		Receiver: receiver,
+		Message:  message,
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+	chat.SendTo(sender, jsonResponse)
+	chat.SendTo(receiver, jsonResponse)
+
+	sendConversationUpdate(sender, receiver)
+	sendConversationUpdate(receiver, sender)
+}
+
+// sendUserRenamed tells everybody that a user changed their nickname, so
+// open chat tabs and lists follow, and the user's own pages speak under the
+// new one
+func sendUserRenamed(oldName, newName string) {
+	response := models.PrivateMessage{
+		Type:    "user_renamed",
+		Sender:  oldName,
+		Message: newName,
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+	chat.Broadcast(jsonResponse)
+}
+
+// postTopic is the hub topic carrying the new comments of a post
+func postTopic(postID int) string {
+	return "post:" + strconv.Itoa(postID)
+}
+
+// sendPostEvent pushes a change to a post (post_created, post_updated or
+// post_deleted) to every connected browser
+func sendPostEvent(eventType string, post *models.Post) {
+	jsonResponse, err := json.Marshal(models.ForumEvent{Type: eventType, Post: post})
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+	chat.Broadcast(jsonResponse)
+}
+
+// sendCommentEvent pushes a change to a comment (comment_created,
+// comment_updated or comment_deleted) to the browsers that have its post
+// open, i.e. subscribed to it with a subscribe frame
+func sendCommentEvent(eventType string, comment *models.Comment) {
+	jsonResponse, err := json.Marshal(models.ForumEvent{Type: eventType, Comment: comment})
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+	chat.Publish(postTopic(comment.PostID), jsonResponse)
+}
+
+// typingInProgress notifies the receiver that someone is typing
+func typingInProgress(msg models.PrivateMessage) {
+	// Ensure both sender and receiver are set
+	if msg.Sender == "" || msg.Receiver == "" {
+		fmt.Println("Error: Sender or receiver not specified")
+		return
+	}
+
+	// Create a response message
+	response := models.PrivateMessage{
+		Type:     "typing",
+		Sender:   msg.Sender,
+		Receiver: msg.Receiver,
+	}
+
+	// Convert response to JSON
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error marshaling JSON:", err)
+		return
+	}
+
+	// Send message to receiver if they're connected
+	chat.SendTo(msg.Receiver, jsonResponse)
+}
+
+// userListMessage builds the frame announcing who is currently connected
+func userListMessage(online []string) []byte {
+	response := models.PrivateMessage{
+		Type:     "user_list",
+		Sender:   "server",
+		Message:  "",
+		UserList: online,
+	}
+
+	// Convert to JSON
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Error marshaling user list:", err)
+		return nil
+	}
+
+	return jsonResponse
+}
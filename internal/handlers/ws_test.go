@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"middlewares"
+	"models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventsUntil reads a socket until a frame of the given type arrives and
+// returns the frames read on the way, that one included
+func eventsUntil(t *testing.T, conn *websocket.Conn, kind string) []models.ForumEvent {
+	t.Helper()
+
+	var events []models.ForumEvent
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for a %s frame: %v", kind, err)
+		}
+		var event models.ForumEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+		if event.Type == kind {
+			return events
+		}
+	}
+}
+
+// subscribed opens a socket that follows the comments of a post
+func subscribed(t *testing.T, server *httptest.Server, token string, postID int) *websocket.Conn {
+	t.Helper()
+
+	conn := dial(t, server, token)
+	if err := conn.WriteJSON(models.PrivateMessage{Type: "subscribe", PostIDs: []int{postID}}); err != nil {
+		t.Fatal(err)
+	}
+	// Frames are handled in order, once the history comes back the
+	// subscription is in place
+	if err := conn.WriteJSON(models.PrivateMessage{Type: "chat_history_request"}); err != nil {
+		t.Fatal(err)
+	}
+	eventsUntil(t, conn, "chat_history")
+	return conn
+}
+
+func TestCommentsOnlyReachTheirPostsFollowers(t *testing.T) {
+	server := setupServer(t)
+	_, token := loggedIn(t, "author", middlewares.RoleUser)
+	post := createPost(t, server, token, "Followed")
+	follower := subscribed(t, server, token, post.ID)
+	bystander := dial(t, server, token)
+	eventsUntil(t, bystander, "user_list")
+
+	status, body := call(t, server, http.MethodPost, "/api/posts/"+strconv.Itoa(post.ID)+"/comments", token,
+		`{"body": "First!"}`)
+	if status != http.StatusOK {
+		t.Fatalf("creating comment: status %d: %s", status, body)
+	}
+	events := eventsUntil(t, follower, "comment_created")
+	if comment := events[len(events)-1].Comment; comment == nil || comment.PostID != post.ID {
+		t.Errorf("follower got the comment %+v", comment)
+	}
+
+	// Posts reach everybody; the bystander gets this one, not the comment
+	next := createPost(t, server, token, "Next")
+	for _, conn := range []*websocket.Conn{follower, bystander} {
+		events := eventsUntil(t, conn, "post_created")
+		if created := events[len(events)-1].Post; created == nil || created.ID != next.ID {
+			t.Errorf("post_created for %+v, want post %d", created, next.ID)
+		}
+		if conn != bystander {
+			continue
+		}
+		for _, event := range events {
+			if event.Type == "comment_created" {
+				t.Error("a socket that doesn't follow the post got its comment")
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package hub
+
+import (
+	"config"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Number of frames a client may have queued before it is considered too slow
+const sendBufferSize = 256
+
+// Number of topics a client may be subscribed to at once
+const maxTopics = 500
+
+// Client is one live WebSocket connection. Only its own writer goroutine ever
+// writes to the connection; everybody else goes through the Hub, which queues
+// frames on the send channel.
+type Client struct {
+	SessionID int
+	conn      *websocket.Conn
+	send      chan []byte
+	topics    map[string]bool // Only touched by Hub.Run
+
+	// username only changes through Hub.Rename, but is read everywhere
+	mu       sync.RWMutex
+	username string
+}
+
+// NewClient wraps an upgraded connection opened by a user's session
+func NewClient(conn *websocket.Conn, username string, sessionID int) *Client {
+	return &Client{
+		username:  username,
+		SessionID: sessionID,
+		conn:      conn,
+		send:      make(chan []byte, sendBufferSize),
+		topics:    make(map[string]bool),
+	}
+}
+
+// Username returns the nickname of the user the connection belongs to
+func (c *Client) Username() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.username
+}
+
+func (c *Client) setUsername(username string) {
+	c.mu.Lock()
+	c.username = username
+	c.mu.Unlock()
+}
+
+// Close sends a close frame with the given code and reason, then shuts the
+// connection down. ReadPump returns right after, which lets the caller
+// unregister the client as usual. Safe to call from any goroutine.
+func (c *Client) Close(code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(config.WS_WRITE_WAIT))
+	c.conn.Close()
+}
+
+// ReadPump reads frames from the connection and hands them to handle until
+// the connection fails. Every pong pushes the read deadline back, so a peer
+// that stops answering pings is considered dead after config.WS_PONG_WAIT.
+func (c *Client) ReadPump(handle func(msg []byte)) error {
+	c.conn.SetReadLimit(int64(config.WS_READ_LIMIT))
+	c.conn.SetReadDeadline(time.Now().Add(config.WS_PONG_WAIT))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(config.WS_PONG_WAIT))
+	})
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		handle(msg)
+	}
+}
+
+// WritePump writes the queued frames to the connection and pings the peer
+// every config.WS_PING_PERIOD, until the hub closes the send channel or a
+// write fails. It must run in its own goroutine, one per client.
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(config.WS_PING_PERIOD)
+	defer func() {
+		ticker.Stop()
+		// Closing the connection also unblocks ReadPump
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(config.WS_WRITE_WAIT))
+			if !ok {
+				// The hub dropped us, tell the browser before closing
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(config.WS_WRITE_WAIT))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
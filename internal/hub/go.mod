@@ -0,0 +1,5 @@
+module hub
+
+go 1.23.6
+
+require github.com/gorilla/websocket v1.5.3
@@ -0,0 +1,341 @@
+// Package hub keeps track of the live WebSocket connections. A single
+// goroutine (Run) owns the registry, so frames never race on a connection
+// and a slow client only ever drops itself.
+package hub
+
+// envelope is a frame addressed to one user or one connection
+type envelope struct {
+	to        string
+	client    *Client
+	data      []byte
+	delivered chan bool
+}
+
+// rename moves a user's connections to their new nickname
+type rename struct {
+	from, to string
+}
+
+// subscription adds or removes a topic of a client
+type subscription struct {
+	client *Client
+	topics []string
+	on     bool
+	done   chan bool
+}
+
+// publication is a frame for the subscribers of a topic
+type publication struct {
+	topic string
+	data  []byte
+}
+
+// query asks Run for the clients matching a condition
+type query struct {
+	match func(c *Client) bool
+	reply chan []*Client
+}
+
+// Hub routes frames to the connected clients. A user may be connected from
+// several tabs or devices at once; they count as online until the last of
+// their connections goes away.
+type Hub struct {
+	clients    map[string]map[*Client]bool
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan []byte
+	direct     chan envelope
+	online     chan chan []string
+	find       chan query
+	rename     chan rename
+	subscribe  chan subscription
+	publish    chan publication
+
+	// presence builds the frame sent to everybody when someone connects or
+	// disconnects, from the usernames currently online
+	presence func(online []string) []byte
+}
+
+// New creates a hub; Run must be started before it is used
+func New(presence func(online []string) []byte) *Hub {
+	return &Hub{
+		clients:    make(map[string]map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan []byte),
+		direct:     make(chan envelope),
+		online:     make(chan chan []string),
+		find:       make(chan query),
+		rename:     make(chan rename),
+		subscribe:  make(chan subscription),
+		publish:    make(chan publication),
+		presence:   presence,
+	}
+}
+
+// Register adds a client to the hub and announces it to everyone
+func (h *Hub) Register(c *Client) {
+	h.register <- c
+}
+
+// Unregister removes a client from the hub and closes its send queue
+func (h *Hub) Unregister(c *Client) {
+	h.unregister <- c
+}
+
+// Broadcast queues a frame for every connected client
+func (h *Hub) Broadcast(data []byte) {
+	h.broadcast <- data
+}
+
+// SendTo queues a frame for every connection of a user and reports whether
+// they are connected
+func (h *Hub) SendTo(username string, data []byte) bool {
+	delivered := make(chan bool, 1)
+	h.direct <- envelope{to: username, data: data, delivered: delivered}
+	return <-delivered
+}
+
+// Reply queues a frame for one specific connection
+func (h *Hub) Reply(c *Client, data []byte) bool {
+	delivered := make(chan bool, 1)
+	h.direct <- envelope{client: c, data: data, delivered: delivered}
+	return <-delivered
+}
+
+// Online returns the usernames that currently have at least one connection
+func (h *Hub) Online() []string {
+	reply := make(chan []string, 1)
+	h.online <- reply
+	return <-reply
+}
+
+// Find returns the connections matching a condition, e.g. the ones opened
+// by a given session. match is called from Run and must not use the hub.
+func (h *Hub) Find(match func(c *Client) bool) []*Client {
+	reply := make(chan []*Client, 1)
+	h.find <- query{match: match, reply: reply}
+	return <-reply
+}
+
+// Rename moves the connections of a user who changed their nickname, so
+// frames addressed to the new one reach them, and announces it to everyone
+func (h *Hub) Rename(from, to string) {
+	h.rename <- rename{from: from, to: to}
+}
+
+// Subscribe makes a client receive the frames published on the given topics.
+// It reports false, without subscribing to any of them, when that would take
+// the client over its topic limit.
+func (h *Hub) Subscribe(c *Client, topics ...string) bool {
+	done := make(chan bool, 1)
+	h.subscribe <- subscription{client: c, topics: topics, on: true, done: done}
+	return <-done
+}
+
+// Unsubscribe stops the frames of the given topics for a client
+func (h *Hub) Unsubscribe(c *Client, topics ...string) {
+	done := make(chan bool, 1)
+	h.subscribe <- subscription{client: c, topics: topics, done: done}
+	<-done
+}
+
+// Publish queues a frame for every client subscribed to a topic
+func (h *Hub) Publish(topic string, data []byte) {
+	h.publish <- publication{topic: topic, data: data}
+}
+
+// Run processes the hub's channels forever
+func (h *Hub) Run() {
+	for {
+		select {
+		case c := <-h.register:
+			conns, online := h.clients[c.Username()]
+			if !online {
+				conns = make(map[*Client]bool)
+				h.clients[c.Username()] = conns
+			}
+			conns[c] = true
+
+			if !online {
+				h.announce()
+			} else if data := h.presenceFrame(); data != nil {
+				// Everybody else already knows, only the new tab needs the list
+				if h.deliver(c, data) {
+					continue
+				}
+				h.announce()
+			}
+
+		case c := <-h.unregister:
+			if h.drop(c) {
+				h.announce()
+			}
+
+		case data := <-h.broadcast:
+			if h.fanOut(h.all(), data) {
+				h.announce()
+			}
+
+		case e := <-h.direct:
+			var targets []*Client
+			if e.client != nil {
+				if h.clients[e.client.Username()][e.client] {
+					targets = []*Client{e.client}
+				}
+			} else {
+				for c := range h.clients[e.to] {
+					targets = append(targets, c)
+				}
+			}
+
+			e.delivered <- len(targets) > 0
+			if h.fanOut(targets, e.data) {
+				h.announce()
+			}
+
+		case reply := <-h.online:
+			reply <- h.usernames()
+
+		case q := <-h.find:
+			var found []*Client
+			for _, c := range h.all() {
+				if q.match(c) {
+					found = append(found, c)
+				}
+			}
+			q.reply <- found
+
+		case r := <-h.rename:
+			conns, online := h.clients[r.from]
+			if !online || r.from == r.to {
+				continue
+			}
+			delete(h.clients, r.from)
+			for c := range conns {
+				c.setUsername(r.to)
+			}
+			// Nicknames are unique, nobody else can be connected as r.to
+			h.clients[r.to] = conns
+			h.announce()
+
+		case s := <-h.subscribe:
+			s.done <- h.setTopics(s.client, s.topics, s.on)
+
+		case p := <-h.publish:
+			var targets []*Client
+			for _, c := range h.all() {
+				if c.topics[p.topic] {
+					targets = append(targets, c)
+				}
+			}
+			if h.fanOut(targets, p.data) {
+				h.announce()
+			}
+		}
+	}
+}
+
+// setTopics subscribes a client to topics, or unsubscribes it. Only call it
+// from Run.
+func (h *Hub) setTopics(c *Client, topics []string, on bool) bool {
+	if !on {
+		for _, topic := range topics {
+			delete(c.topics, topic)
+		}
+		return true
+	}
+
+	added := make(map[string]bool)
+	for _, topic := range topics {
+		if !c.topics[topic] {
+			added[topic] = true
+		}
+	}
+	if len(c.topics)+len(added) > maxTopics {
+		return false
+	}
+	for _, topic := range topics {
+		c.topics[topic] = true
+	}
+	return true
+}
+
+// all returns every registered connection. Only call it from Run.
+func (h *Hub) all() []*Client {
+	var clients []*Client
+	for _, conns := range h.clients {
+		for c := range conns {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// presenceFrame builds the presence frame for the users currently online
+func (h *Hub) presenceFrame() []byte {
+	if h.presence == nil {
+		return nil
+	}
+
+	return h.presence(h.usernames())
+}
+
+// usernames lists the users currently online. Only call it from Run.
+func (h *Hub) usernames() []string {
+	users := make([]string, 0, len(h.clients))
+	for username := range h.clients {
+		users = append(users, username)
+	}
+	return users
+}
+
+// announce sends the presence frame to everybody
+func (h *Hub) announce() {
+	if data := h.presenceFrame(); data != nil {
+		// Users going offline here will show up in the next announcement
+		h.fanOut(h.all(), data)
+	}
+}
+
+// fanOut queues a frame for the given clients and reports whether a user
+// went offline because their last connection was too slow
+func (h *Hub) fanOut(clients []*Client, data []byte) bool {
+	wentOffline := false
+	for _, c := range clients {
+		if !h.deliver(c, data) && h.clients[c.Username()] == nil {
+			wentOffline = true
+		}
+	}
+	return wentOffline
+}
+
+// deliver queues a frame without blocking; a client whose queue is full is
+// too slow to keep up and gets disconnected
+func (h *Hub) deliver(c *Client, data []byte) bool {
+	select {
+	case c.send <- data:
+		return true
+	default:
+		h.drop(c)
+		return false
+	}
+}
+
+// drop forgets a client and closes its queue, which stops its writer.
+// It reports whether that was the user's last connection.
+func (h *Hub) drop(c *Client) bool {
+	conns := h.clients[c.Username()]
+	if !conns[c] {
+		return false
+	}
+
+	delete(conns, c)
+	close(c.send)
+
+	if len(conns) > 0 {
+		return false
+	}
+	delete(h.clients, c.Username())
+	return true
+}
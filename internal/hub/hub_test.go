@@ -0,0 +1,306 @@
+package hub
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// startHub runs a hub whose presence frame is just the word "presence"
+func startHub(t *testing.T) *Hub {
+	t.Helper()
+
+	h := New(func([]string) []byte {
+		return []byte("presence")
+	})
+	go h.Run()
+	return h
+}
+
+// queued takes the frames waiting in a client's queue, without blocking
+func queued(c *Client) []string {
+	var frames []string
+	for {
+		select {
+		case data, ok := <-c.send:
+			if !ok {
+				return frames
+			}
+			frames = append(frames, string(data))
+		default:
+			return frames
+		}
+	}
+}
+
+// closed reports whether the hub closed a client's queue
+func closed(c *Client) bool {
+	for {
+		select {
+		case _, ok := <-c.send:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func TestSendToOnlyReachesTheAddressee(t *testing.T) {
+	h := startHub(t)
+	alice := NewClient(nil, "alice", 1)
+	bob := NewClient(nil, "bob", 2)
+	h.Register(alice)
+	h.Register(bob)
+	h.Online() // Wait for Run to handle the registrations
+	queued(alice)
+	queued(bob)
+
+	if !h.SendTo("bob", []byte("hello")) {
+		t.Fatal("SendTo reported bob offline")
+	}
+	if h.SendTo("carol", []byte("hello")) {
+		t.Error("SendTo reported an unknown user online")
+	}
+	h.Online()
+
+	if frames := queued(bob); len(frames) != 1 || frames[0] != "hello" {
+		t.Errorf("bob got %q", frames)
+	}
+	if frames := queued(alice); len(frames) != 0 {
+		t.Errorf("alice got %q", frames)
+	}
+}
+
+func TestSlowClientOnlyDropsItself(t *testing.T) {
+	h := startHub(t)
+	slow := NewClient(nil, "slow", 1)
+	fast := NewClient(nil, "fast", 2)
+	h.Register(slow)
+	h.Register(fast)
+	h.Online()
+	queued(slow)
+
+	// Nobody drains slow's queue, fast keeps up
+	for i := 0; i <= sendBufferSize; i++ {
+		h.Broadcast([]byte("frame"))
+		queued(fast)
+	}
+	h.Online()
+
+	if !closed(slow) {
+		t.Error("the queue of a client that can't keep up wasn't closed")
+	}
+	if online := h.Online(); len(online) != 1 || online[0] != "fast" {
+		t.Errorf("online = %v, want only fast", online)
+	}
+	if !h.SendTo("fast", []byte("still here")) {
+		t.Error("the client keeping up was dropped too")
+	}
+}
+
+func TestUnregisterAnnouncesTheDeparture(t *testing.T) {
+	h := startHub(t)
+	alice := NewClient(nil, "alice", 1)
+	bob := NewClient(nil, "bob", 2)
+	h.Register(alice)
+	h.Register(bob)
+	h.Online()
+	queued(alice)
+
+	h.Unregister(bob)
+	h.Online()
+
+	if !closed(bob) {
+		t.Error("an unregistered client's queue is still open")
+	}
+	if frames := strings.Join(queued(alice), ","); frames != "presence" {
+		t.Errorf("alice got %q, want the new presence", frames)
+	}
+}
+
+func TestUserStaysOnlineUntilTheirLastConnection(t *testing.T) {
+	h := startHub(t)
+	watcher := NewClient(nil, "watcher", 1)
+	laptop := NewClient(nil, "alice", 2)
+	phone := NewClient(nil, "alice", 3)
+	h.Register(watcher)
+	h.Register(laptop)
+	h.Online()
+	queued(watcher)
+	queued(laptop)
+
+	// A second tab only gets the list, the others already know
+	h.Register(phone)
+	h.Online()
+	if frames := queued(phone); len(frames) != 1 || frames[0] != "presence" {
+		t.Errorf("new tab got %q, want the presence", frames)
+	}
+	if frames := queued(watcher); len(frames) != 0 {
+		t.Errorf("a second tab was announced: %q", frames)
+	}
+
+	if !h.SendTo("alice", []byte("hello")) {
+		t.Fatal("SendTo reported alice offline")
+	}
+	h.Online()
+	for _, c := range []*Client{laptop, phone} {
+		if frames := queued(c); len(frames) != 1 || frames[0] != "hello" {
+			t.Errorf("connection %d got %q", c.SessionID, frames)
+		}
+	}
+
+	h.Unregister(laptop)
+	if online := strings.Join(h.Online(), ","); !strings.Contains(online, "alice") {
+		t.Errorf("alice went offline with a connection left: %s", online)
+	}
+	if frames := queued(watcher); len(frames) != 0 {
+		t.Errorf("closing one of two tabs was announced: %q", frames)
+	}
+
+	h.Unregister(phone)
+	if online := strings.Join(h.Online(), ","); strings.Contains(online, "alice") {
+		t.Errorf("alice still online without connections: %s", online)
+	}
+	if frames := queued(watcher); len(frames) != 1 {
+		t.Errorf("watcher got %q, want the departure", frames)
+	}
+}
+
+func TestReplyOnlyReachesOneConnection(t *testing.T) {
+	h := startHub(t)
+	laptop := NewClient(nil, "alice", 1)
+	phone := NewClient(nil, "alice", 2)
+	h.Register(laptop)
+	h.Register(phone)
+	h.Online()
+	queued(laptop)
+	queued(phone)
+
+	if !h.Reply(phone, []byte("history")) {
+		t.Fatal("Reply reported the connection gone")
+	}
+	h.Online()
+	if frames := queued(phone); len(frames) != 1 {
+		t.Errorf("phone got %q", frames)
+	}
+	if frames := queued(laptop); len(frames) != 0 {
+		t.Errorf("laptop got %q", frames)
+	}
+
+	h.Unregister(phone)
+	if h.Reply(phone, []byte("history")) {
+		t.Error("Reply reached an unregistered connection")
+	}
+}
+
+func TestRenameMovesEveryConnection(t *testing.T) {
+	h := startHub(t)
+	watcher := NewClient(nil, "watcher", 1)
+	laptop := NewClient(nil, "alice", 2)
+	phone := NewClient(nil, "alice", 3)
+	h.Register(watcher)
+	h.Register(laptop)
+	h.Register(phone)
+	h.Online()
+	queued(watcher)
+
+	h.Rename("alice", "alicia")
+	online := map[string]bool{}
+	for _, username := range h.Online() {
+		online[username] = true
+	}
+	if len(online) != 2 || !online["alicia"] || !online["watcher"] {
+		t.Errorf("online after the rename: %v", online)
+	}
+	if frames := queued(watcher); len(frames) != 1 || frames[0] != "presence" {
+		t.Errorf("watcher got %q, want the new presence", frames)
+	}
+	for _, c := range []*Client{laptop, phone} {
+		if c.Username() != "alicia" {
+			t.Errorf("connection %d is still %s", c.SessionID, c.Username())
+		}
+		queued(c)
+	}
+
+	if h.SendTo("alice", []byte("hello")) {
+		t.Error("the old nickname still reaches the connections")
+	}
+	if !h.SendTo("alicia", []byte("hello")) {
+		t.Fatal("SendTo reported alicia offline")
+	}
+	h.Online()
+	for _, c := range []*Client{laptop, phone} {
+		if frames := queued(c); len(frames) != 1 || frames[0] != "hello" {
+			t.Errorf("connection %d got %q", c.SessionID, frames)
+		}
+	}
+}
+
+func TestPublishOnlyReachesSubscribers(t *testing.T) {
+	h := startHub(t)
+	alice := NewClient(nil, "alice", 1)
+	bob := NewClient(nil, "bob", 2)
+	h.Register(alice)
+	h.Register(bob)
+	h.Online()
+	queued(alice)
+	queued(bob)
+
+	if !h.Subscribe(alice, "post:1", "post:2") {
+		t.Fatal("Subscribe refused two topics")
+	}
+	h.Publish("post:1", []byte("comment"))
+	h.Publish("post:3", []byte("elsewhere"))
+	h.Online()
+	if frames := queued(alice); len(frames) != 1 || frames[0] != "comment" {
+		t.Errorf("alice got %q", frames)
+	}
+	if frames := queued(bob); len(frames) != 0 {
+		t.Errorf("bob got %q without subscribing", frames)
+	}
+
+	h.Unsubscribe(alice, "post:1")
+	h.Publish("post:1", []byte("comment"))
+	h.Publish("post:2", []byte("still followed"))
+	h.Online()
+	if frames := queued(alice); len(frames) != 1 || frames[0] != "still followed" {
+		t.Errorf("alice got %q after unsubscribing", frames)
+	}
+}
+
+func TestSubscribeStopsAtTheTopicLimit(t *testing.T) {
+	h := startHub(t)
+	alice := NewClient(nil, "alice", 1)
+	h.Register(alice)
+
+	topics := make([]string, maxTopics)
+	for i := range topics {
+		topics[i] = "post:" + strconv.Itoa(i)
+	}
+	if !h.Subscribe(alice, topics...) {
+		t.Fatalf("Subscribe refused %d topics", maxTopics)
+	}
+	// Topics already followed don't count twice
+	if !h.Subscribe(alice, topics[0]) {
+		t.Error("Subscribe refused a topic already followed")
+	}
+	if h.Subscribe(alice, topics[0], "post:new") {
+		t.Error("Subscribe went over the limit")
+	}
+
+	// The refused call subscribed to none of its topics
+	h.Online()
+	queued(alice)
+	h.Publish("post:new", []byte("comment"))
+	h.Online()
+	if frames := queued(alice); len(frames) != 0 {
+		t.Errorf("alice got %q from a refused topic", frames)
+	}
+
+	h.Unsubscribe(alice, topics[1])
+	if !h.Subscribe(alice, "post:new") {
+		t.Error("Subscribe refused a topic after making room")
+	}
+}
@@ -0,0 +1,54 @@
+// Package mailer delivers the emails the forum sends its users, such as
+// password reset links. Handlers only know the Mailer interface, so the
+// delivery method can be swapped without touching them.
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mailer sends a plain text email
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// LogMailer is the Mailer for local development: instead of sending emails
+// it writes each of them to a file in Dir, or to the log when Dir is empty
+type LogMailer struct {
+	Dir string
+}
+
+// NewLogMailer creates a LogMailer writing to dir, created if needed
+func NewLogMailer(dir string) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating mail directory: %v", err)
+		}
+	}
+	return &LogMailer{Dir: dir}, nil
+}
+
+// Send writes the email where the developer can read it
+func (m *LogMailer) Send(to, subject, body string) error {
+	now := time.Now()
+	message := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n",
+		now.Format(time.RFC1123Z), to, subject, body)
+
+	if m.Dir == "" {
+		log.Printf("Email not sent (no mailer configured):\n%s", message)
+		return nil
+	}
+
+	// One file per email, named so they sort by date
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(to)
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405.000000000"), recipient)
+	if err := os.WriteFile(filepath.Join(m.Dir, name), []byte(message), 0644); err != nil {
+		return fmt.Errorf("error writing email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"config"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// User is the authenticated user a request was made by
+type User struct {
+	ID            int
+	Username      string
+	Role          string
+	EmailVerified bool
+	SessionID     int    // Row of the session in the database
+	SessionToken  string // Value of the session_id cookie
+}
+
+// contextKey keeps our context values apart from other packages'
+type contextKey int
+
+const userKey contextKey = iota
+
+// RequireAuth only lets requests with a valid session through; the others
+// get a 401, as JSON for the API routes. An expired session is reported
+// with the code "session_expired".
+func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := authenticate(w, r)
+		if err != nil {
+			message := "Authentication required"
+			if err == ErrSessionExpired {
+				message = "Session expired"
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": message, "code": err.Error()})
+				return
+			}
+			http.Error(w, message, http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+	}
+}
+
+// ErrEmailUnverified is the code returned to accounts that have to verify
+// their email address first
+var ErrEmailUnverified = errors.New("email_unverified")
+
+// RequireVerified is RequireAuth for publishing: when
+// config.REQUIRE_VERIFIED_EMAIL is set, accounts that haven't verified their
+// email address yet get a 403
+func RequireVerified(next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := CurrentUser(r)
+		if !MayPublish(user.EmailVerified) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Please verify your email address first",
+				"code":  ErrEmailUnverified.Error(),
+			})
+			return
+		}
+		next(w, r)
+	})
+}
+
+// MayPublish reports whether an account may post, comment and send
+// messages, given whether its email address is verified
+func MayPublish(emailVerified bool) bool {
+	return emailVerified || !config.REQUIRE_VERIFIED_EMAIL
+}
+
+// OptionalAuth resolves the session when there is one and lets every
+// request through
+func OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if user, err := authenticate(w, r); err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), userKey, user))
+		}
+		next(w, r)
+	}
+}
+
+// CurrentUser returns the user RequireAuth or OptionalAuth found for the
+// request, and false when it was made anonymously
+func CurrentUser(r *http.Request) (User, bool) {
+	user, ok := r.Context().Value(userKey).(User)
+	return user, ok
+}
+
+// errNoSession means the request came without a session cookie
+var errNoSession = errors.New("session_missing")
+
+// authenticate resolves the session named by the session_id cookie. The
+// cookie is pushed back while the session is in use and dropped once it
+// can't be used anymore.
+func authenticate(w http.ResponseWriter, r *http.Request) (User, error) {
+	cookie, err := r.Cookie("session_id")
+	if err != nil {
+		return User{}, errNoSession
+	}
+
+	session, err := LookupSession(cookie.Value)
+	if err != nil {
+		ClearSessionCookie(w)
+		return User{}, err
+	}
+	if needsTouch(&session) {
+		setSessionCookie(w, cookie.Value, cookieExpiry(session.ExpiresAt))
+	}
+
+	return User{
+		ID:            session.UserID,
+		Username:      session.Username,
+		Role:          session.Role,
+		EmailVerified: session.EmailVerified,
+		SessionID:     session.ID,
+		SessionToken:  cookie.Value,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequireVerifiedFollowsTheSetting(t *testing.T) {
+	s, userID := testUser(t)
+	token := GenerateSessionID()
+	if err := StoreSession(token, userID, time.Now().Add(time.Hour), "test", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	handler := RequireVerified(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	status := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/postCreation", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: token})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	required := config.REQUIRE_VERIFIED_EMAIL
+	t.Cleanup(func() { config.REQUIRE_VERIFIED_EMAIL = required })
+
+	config.REQUIRE_VERIFIED_EMAIL = false
+	if code := status(); code != http.StatusNoContent {
+		t.Errorf("unverified, not required: status %d", code)
+	}
+	if !MayPublish(false) {
+		t.Error("MayPublish refuses unverified users when it isn't required")
+	}
+
+	config.REQUIRE_VERIFIED_EMAIL = true
+	if code := status(); code != http.StatusForbidden {
+		t.Errorf("unverified, required: status %d", code)
+	}
+	if MayPublish(false) || !MayPublish(true) {
+		t.Error("MayPublish doesn't follow the email verification")
+	}
+
+	if _, err := s.DB.Exec(`UPDATE user SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?`, userID); err != nil {
+		t.Fatal(err)
+	}
+	if code := status(); code != http.StatusNoContent {
+		t.Errorf("verified, required: status %d", code)
+	}
+}
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"config"
+	"fmt"
+	"time"
+)
+
+// RunJanitor purges the expired sessions every
+// config.SESSION_CLEANUP_INTERVAL, forever. expired is told which sessions
+// were removed so whatever they had open can be closed too.
+func RunJanitor(expired func(sessionIDs []int)) {
+	ticker := time.NewTicker(config.SESSION_CLEANUP_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ids, err := store.SessionDeleteExpired()
+		if err != nil {
+			fmt.Println("Error purging expired sessions:", err)
+			continue
+		}
+		if len(ids) > 0 {
+			fmt.Printf("Purged %d expired session(s)\n", len(ids))
+			expired(ids)
+		}
+	}
+}
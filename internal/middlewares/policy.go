@@ -0,0 +1,57 @@
+package middlewares
+
+// Roles a user can have, stored in the user's role column
+const (
+	RoleUser      = "User"
+	RoleModerator = "Moderator"
+	RoleAdmin     = "Admin"
+)
+
+// Permission is something a role allows beyond managing one's own content
+type Permission int
+
+const (
+	// ModerateContent allows editing and deleting anybody's posts and comments
+	ModerateContent Permission = iota
+	// ManageRoles allows changing the role of other users
+	ManageRoles
+	// ManageUsers allows deleting and restoring other users' accounts
+	ManageUsers
+)
+
+// rolePermissions lists what each role is allowed to do. A user with an
+// unknown role only manages their own content.
+var rolePermissions = map[string][]Permission{
+	RoleUser:      {},
+	RoleModerator: {ModerateContent},
+	RoleAdmin:     {ModerateContent, ManageRoles, ManageUsers},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can reports whether the user's role grants a permission
+func (u User) Can(p Permission) bool {
+	for _, granted := range rolePermissions[u.Role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanModify reports whether the user may edit or delete content written by
+// authorID: their own, or anybody's for moderators
+func (u User) CanModify(authorID int) bool {
+	return (u.ID != 0 && u.ID == authorID) || u.Can(ModerateContent)
+}
+
+// CanRestore reports whether the user may bring back deleted content written
+// by authorID and deleted by deletedBy. Authors can only undo their own
+// deletions, not a moderator's.
+func (u User) CanRestore(authorID, deletedBy int) bool {
+	return (u.ID != 0 && u.ID == authorID && u.ID == deletedBy) || u.Can(ModerateContent)
+}
@@ -0,0 +1,90 @@
+package middlewares
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{RoleUser, ModerateContent, false},
+		{RoleUser, ManageRoles, false},
+		{RoleUser, ManageUsers, false},
+		{RoleModerator, ModerateContent, true},
+		{RoleModerator, ManageRoles, false},
+		{RoleModerator, ManageUsers, false},
+		{RoleAdmin, ModerateContent, true},
+		{RoleAdmin, ManageRoles, true},
+		{RoleAdmin, ManageUsers, true},
+		{"Superuser", ModerateContent, false},
+		{"", ManageRoles, false},
+	}
+	for _, tt := range tests {
+		user := User{ID: 1, Role: tt.role}
+		if got := user.Can(tt.permission); got != tt.want {
+			t.Errorf("%q.Can(%d) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestCanModify(t *testing.T) {
+	const author, other = 1, 2
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"author", User{ID: author, Role: RoleUser}, true},
+		{"someone else", User{ID: other, Role: RoleUser}, false},
+		{"moderator", User{ID: other, Role: RoleModerator}, true},
+		{"admin", User{ID: other, Role: RoleAdmin}, true},
+		{"unknown role", User{ID: other, Role: "Superuser"}, false},
+		{"nobody", User{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.user.CanModify(author); got != tt.want {
+			t.Errorf("%s: CanModify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Anonymous content doesn't belong to the anonymous user
+	if (User{}).CanModify(0) {
+		t.Error("a user without an ID can modify content without an author")
+	}
+}
+
+func TestValidRole(t *testing.T) {
+	for _, role := range []string{RoleUser, RoleModerator, RoleAdmin} {
+		if !ValidRole(role) {
+			t.Errorf("ValidRole(%q) = false", role)
+		}
+	}
+	for _, role := range []string{"", "admin", "Superuser"} {
+		if ValidRole(role) {
+			t.Errorf("ValidRole(%q) = true", role)
+		}
+	}
+}
+
+func TestCanRestore(t *testing.T) {
+	const author, moderator, other = 1, 2, 3
+	tests := []struct {
+		name      string
+		user      User
+		deletedBy int
+		want      bool
+	}{
+		{"author undoing their deletion", User{ID: author, Role: RoleUser}, author, true},
+		{"author undoing a moderator", User{ID: author, Role: RoleUser}, moderator, false},
+		{"someone else", User{ID: other, Role: RoleUser}, other, false},
+		{"moderator", User{ID: moderator, Role: RoleModerator}, author, true},
+		{"admin", User{ID: other, Role: RoleAdmin}, moderator, true},
+		{"nobody", User{}, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.user.CanRestore(author, tt.deletedBy); got != tt.want {
+			t.Errorf("%s: CanRestore = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
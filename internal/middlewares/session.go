@@ -1,96 +1,177 @@
 package middlewares
 
 import (
+	"config"
+	"crypto/rand"
+	"crypto/sha256"
+	"db"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"models"
+	"net"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func GenerateSessionID() string {
-	return uuid.New().String()
+// Session is the login a request was made with
+type Session = models.Session
+
+// store holds the sessions, set once by Init
+var store *db.Store
+
+// Init hands the session functions the store opened at startup
+func Init(s *db.Store) {
+	store = s
 }
 
-func CreateSession(w http.ResponseWriter, userID int, username, role, uuid string) {
-	oldSessionID, exists := SessionExists(userID)
-	if exists {
-		DeleteSession(oldSessionID)
-	}
+// GenerateUUID returns a new public identifier for a user
+func GenerateUUID() string {
+	return uuid.New().String()
+}
 
-	// Set cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    uuid,
-		Expires:  time.Now().Add(24 * time.Hour),
-		HttpOnly: true,
-		Secure:   true, // Set to true if using HTTPS
-		SameSite: http.SameSiteLaxMode,
-	})
+// GenerateSessionID returns a random, opaque session token
+func GenerateSessionID() string {
+	return GenerateToken()
+}
 
-	// Store session in server (implement this function)
-	StoreSession(uuid, userID, username, role)
+// GenerateToken returns 32 random bytes, safe to put in a cookie or a URL
+func GenerateToken() string {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		panic(fmt.Sprintf("error generating token: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
 }
 
-type Session struct {
-	UserID    int
-	Username  string
-	Role      string
-	CreatedAt time.Time
+// HashToken is what the database knows a token by, so a leaked database
+// doesn't hand out working cookies or links
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
+// Reasons a session cookie is refused, also used as the code returned to
+// the frontend and as the WebSocket close reason
 var (
-	sessions = make(map[string]Session)
-	mutex    sync.RWMutex
+	ErrSessionInvalid = errors.New("session_invalid")
+	ErrSessionExpired = errors.New("session_expired")
 )
 
-func StoreSession(sessionID string, userID int, username, role string) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	sessions[sessionID] = Session{
-		UserID:    userID,
-		Username:  username,
-		Role:      role,
-		CreatedAt: time.Now(),
+// CreateSession logs a user in on this device, next to their other sessions
+func CreateSession(w http.ResponseWriter, r *http.Request, userID int) error {
+	sessionID := GenerateSessionID()
+	expiresAt := time.Now().Add(config.SESSION_LIFETIME)
+	if err := StoreSession(sessionID, userID, expiresAt, r.UserAgent(), ClientIP(r)); err != nil {
+		return err
 	}
+
+	setSessionCookie(w, sessionID, cookieExpiry(expiresAt))
+	return nil
+}
+
+func StoreSession(sessionID string, userID int, expiresAt time.Time, userAgent, ip string) error {
+	return store.SessionInsert(HashToken(sessionID), userID, expiresAt, userAgent, ip)
 }
 
 func GetSession(sessionID string) (Session, bool) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	session, exists := sessions[sessionID]
-	return session, exists
-}
-
-func SessionExists(userID int) (string, bool) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	for sessionID, session := range sessions {
-		if session.UserID == userID {
-			return sessionID, true
+	session, err := LookupSession(sessionID)
+	return session, err == nil
+}
+
+// LookupSession returns the session a token belongs to, or why it can't be
+// used. Using a session keeps it from going idle.
+func LookupSession(sessionID string) (Session, error) {
+	session, err := store.SessionSelectByTokenHash(HashToken(sessionID))
+	if err != nil {
+		return Session{}, ErrSessionInvalid
+	}
+	return use(session)
+}
+
+// LookupSessionByID is LookupSession from the session's database ID, for
+// code that outlives the request it was authenticated with, like a WebSocket
+func LookupSessionByID(id int) (Session, error) {
+	session, err := store.SessionSelectByID(id)
+	if err != nil {
+		return Session{}, ErrSessionInvalid
+	}
+	return use(session)
+}
+
+// use checks that a session hasn't expired and records that it is active
+func use(session *Session) (Session, error) {
+	now := time.Now()
+	if !now.Before(session.ExpiresAt) || now.Sub(session.LastSeen) >= config.SESSION_IDLE_TIMEOUT {
+		return Session{}, ErrSessionExpired
+	}
+
+	if needsTouch(session) {
+		if err := store.SessionTouch(session.ID); err != nil {
+			fmt.Println("Error updating session:", err)
 		}
 	}
-	return "", false
+	return *session, nil
 }
 
-func DeleteSession(sessionID string) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	delete(sessions, sessionID)
+// needsTouch reports whether last_seen is stale enough to be written again.
+// It doesn't need to be exact, this spares a write on every request.
+func needsTouch(session *Session) bool {
+	interval := time.Minute
+	if idle := config.SESSION_IDLE_TIMEOUT / 10; idle < interval {
+		interval = idle
+	}
+	return time.Since(session.LastSeen) >= interval
 }
 
-func GetCookie(w http.ResponseWriter, r *http.Request) Session {
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		//http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return Session{}
+// cookieExpiry is when the browser should drop the cookie of a session used
+// just now: once it goes idle, or at the end of its lifetime
+func cookieExpiry(expiresAt time.Time) time.Time {
+	idle := time.Now().Add(config.SESSION_IDLE_TIMEOUT)
+	if idle.Before(expiresAt) {
+		return idle
 	}
+	return expiresAt
+}
 
-	sessionID := cookie.Value
-	session, exists := GetSession(sessionID)
-	if !exists {
-		//http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return Session{}
+func setSessionCookie(w http.ResponseWriter, sessionID string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true, // Set to true if using HTTPS
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie makes the browser forget its session
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0), // Expire immédiatement
+		HttpOnly: true,
+		Secure:   true, // Mets false si tu es en développement sans HTTPS
+	})
+}
+
+func DeleteSession(sessionID string) {
+	if err := store.SessionDeleteByTokenHash(HashToken(sessionID)); err != nil {
+		fmt.Println("Error deleting session:", err)
+	}
+}
+
+// ClientIP returns the address a request came from, without its port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
-	return session
+	return host
 }
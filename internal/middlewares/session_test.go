@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"config"
+	"db"
+	"db/migrations"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testUser sets up the sessions on a fresh in-memory database and returns
+// it with the ID of a registered user
+func testUser(t *testing.T) (*db.Store, int) {
+	t.Helper()
+
+	s, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := migrations.Up(s.DB); err != nil {
+		t.Fatal(err)
+	}
+	Init(s)
+
+	id, msg := s.UserInsert(GenerateUUID(), "member", "Other", "First", "Last",
+		"member@example.com", "Passw0rd!", RoleUser, 0)
+	if id == 0 {
+		t.Fatalf("creating user: %s", msg)
+	}
+	return s, id
+}
+
+func TestLookupSessionKnowsTheTokenOnlyByItsHash(t *testing.T) {
+	s, userID := testUser(t)
+	token := GenerateSessionID()
+	if err := StoreSession(token, userID, time.Now().Add(time.Hour), "test", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := LookupSession(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserID != userID {
+		t.Errorf("session belongs to user %d, want %d", session.UserID, userID)
+	}
+	if _, err := s.SessionSelectByTokenHash(token); err == nil {
+		t.Error("the database knows the session by its raw token")
+	}
+	if _, err := LookupSession(GenerateSessionID()); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("unknown token: %v, want %v", err, ErrSessionInvalid)
+	}
+
+	DeleteSession(token)
+	if _, err := LookupSession(token); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("deleted session: %v, want %v", err, ErrSessionInvalid)
+	}
+}
+
+// lastSeen pretends a session was last used at the given time
+func lastSeen(t *testing.T, s *db.Store, token string, at time.Time) {
+	t.Helper()
+
+	_, err := s.DB.Exec(`UPDATE session SET last_seen = ? WHERE token_hash = ?`,
+		at.UTC().Format("2006-01-02 15:04:05"), HashToken(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookupSessionRefusesExpiredSessions(t *testing.T) {
+	s, userID := testUser(t)
+
+	ended := GenerateSessionID()
+	if err := StoreSession(ended, userID, time.Now().Add(-time.Minute), "test", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LookupSession(ended); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("session past its lifetime: %v, want %v", err, ErrSessionExpired)
+	}
+
+	idle := GenerateSessionID()
+	if err := StoreSession(idle, userID, time.Now().Add(time.Hour), "test", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	lastSeen(t, s, idle, time.Now().Add(-config.SESSION_IDLE_TIMEOUT-time.Minute))
+	if _, err := LookupSession(idle); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("idle session: %v, want %v", err, ErrSessionExpired)
+	}
+}
+
+func TestLookupSessionKeepsTheSessionFromGoingIdle(t *testing.T) {
+	s, userID := testUser(t)
+	token := GenerateSessionID()
+	if err := StoreSession(token, userID, time.Now().Add(time.Hour), "test", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	lastSeen(t, s, token, time.Now().Add(-config.SESSION_IDLE_TIMEOUT/2))
+
+	if _, err := LookupSession(token); err != nil {
+		t.Fatal(err)
+	}
+	session, err := s.SessionSelectByTokenHash(HashToken(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since := time.Since(session.LastSeen); since > time.Minute {
+		t.Errorf("last_seen is %s old after using the session", since)
+	}
+}
+
+func TestCookieExpiryIsTheEarlierOfIdleAndLifetime(t *testing.T) {
+	soon := time.Now().Add(time.Minute)
+	if got := cookieExpiry(soon); !got.Equal(soon) {
+		t.Errorf("session ending before going idle: cookie expires %v, want %v", got, soon)
+	}
+
+	later := time.Now().Add(config.SESSION_IDLE_TIMEOUT + time.Hour)
+	got := cookieExpiry(later)
+	if want := time.Now().Add(config.SESSION_IDLE_TIMEOUT); got.Sub(want) > time.Second || want.Sub(got) > time.Second {
+		t.Errorf("long session: cookie expires %v, want about %v", got, want)
+	}
+}
@@ -2,18 +2,41 @@ package models
 
 // ChatHistoryMessage represents a single message in the chat history
 type ChatHistoryMessage struct {
+	ID        int    `json:"id"`
 	Type      string `json:"type"`
 	SenderID  int    `json:"sender_id"`
 	Sender    string `json:"sender"` // Username of the sender
 	Message   string `json:"message"`
-	Timestamp string `json:"timestamp"` // Creation time as string
-	Read      bool   `json:"read"`      // Whether the message has been read
+	Timestamp string `json:"timestamp"`         // Creation time as string
+	Read      bool   `json:"read"`              // Whether the message has been read
+	Deleted   bool   `json:"deleted,omitempty"` // Message is a placeholder, its sender deleted it
 }
 
-// ChatHistory represents the full history of messages between two users
+// ChatHistory represents one page of the history of messages between two users
 type ChatHistory struct {
 	Type      string               `json:"type"`
 	User1Name string               `json:"user1name"`
 	User2Name string               `json:"user2name"`
 	Messages  []ChatHistoryMessage `json:"messages"`
+	Before    int                  `json:"before,omitempty"` // Cursor this page was requested with
+	HasMore   bool                 `json:"has_more"`         // Whether older messages exist
+}
+
+// PendingMessages carries the messages a user received while offline
+type PendingMessages struct {
+	Type     string               `json:"type"`
+	Messages []ChatHistoryMessage `json:"messages"`
+}
+
+// Conversation is one entry of a user's conversation list, describing the
+// last message exchanged with another user
+type Conversation struct {
+	Type          string `json:"type,omitempty"`
+	User          string `json:"user"` // Username of the other participant
+	LastMessageID int    `json:"last_message_id"`
+	LastMessage   string `json:"last_message"` // Preview of the most recent message
+	LastSender    string `json:"last_sender"`
+	Timestamp     string `json:"timestamp"`
+	Unread        int    `json:"unread"` // Messages from User not read yet
+	Online        bool   `json:"online"`
 }
@@ -9,3 +9,16 @@ type CustomError struct {
 func NewError(status int, msg string) *CustomError {
 	return &CustomError{StatusCode: status, Message: msg}
 }
+
+// Error lets CustomError travel as a regular error value
+func (e *CustomError) Error() string {
+	return e.Message
+}
+
+// FieldError tells which field of a form was refused and why. Code is meant
+// for the frontend, Message for the user.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
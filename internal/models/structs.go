@@ -18,16 +18,54 @@ type User struct {
 }
 
 type Post struct {
-	ID        int
-	UserID    int
-	Username  string
-	Title     string
-	Body      string
-	Status    string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	User      User
-	Comments  []Comment
+	ID         int
+	UserID     int
+	Username   string
+	Title      string
+	Body       string
+	Status     string
+	Categories []string
+	// Number of comments, only filled in the feed
+	CommentCount int
+	Revisions    int // Number of earlier versions
+	DeletedBy    int `json:"-"` // Who deleted it, only filled for deleted posts
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	User         User
+	Comments     []Comment
+}
+
+// FeedPage is one page of the post feed. NextCursor is passed as before to
+// get the following page, PrevCursor as after to get the posts above it.
+type FeedPage struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// ForumEvent tells the connected browsers about a post or comment that was
+// created, updated or deleted
+type ForumEvent struct {
+	Type    string   `json:"type"` // e.g. post_created or comment_deleted
+	Post    *Post    `json:"post,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// Revision is an earlier version of a post or comment, replaced by an edit
+type Revision struct {
+	ID       int       `json:"id"`
+	Title    string    `json:"title,omitempty"` // Posts only
+	Body     string    `json:"body"`
+	EditedBy string    `json:"edited_by"` // Nickname of who made the edit
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// Category groups posts by topic; a post is in one or more of them
+type Category struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
 }
 
 type Comment struct {
@@ -39,6 +77,9 @@ type Comment struct {
 	UpdatedAt time.Time
 	Username  string
 	PostTitle string
+	Revisions int  // Number of earlier versions
+	Deleted   bool // A placeholder for a deleted comment in its thread
+	DeletedBy int  `json:"-"` // Who deleted it, only filled for deleted comments
 }
 
 // Updated to match notification.go implementation
@@ -59,19 +100,32 @@ type Activity struct {
 	Timestamp string
 }
 
+// Session is a login, identified by the token stored in the session_id cookie
 type Session struct {
-	UserID    int
-	Username  string
-	CreatedAt time.Time
+	ID            int
+	UserID        int
+	Username      string
+	Role          string
+	EmailVerified bool // Whether the user has verified their email address
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	LastSeen      time.Time
+	UserAgent     string
+	IP            string
 }
 
 // Struct that will store the content of the private message
 type PrivateMessage struct {
-	Type     string   `json:"type"`
-	Sender   string   `json:"sender"`
-	Receiver string   `json:"receiver"`
-	Message  string   `json:"message"`
-	UserList []string `json:"user_list,omitempty"`
+	ID         int      `json:"id,omitempty"`
+	Type       string   `json:"type"`
+	Sender     string   `json:"sender"`
+	Receiver   string   `json:"receiver"`
+	Message    string   `json:"message"`
+	UserList   []string `json:"user_list,omitempty"`
+	MessageIDs []int    `json:"message_ids,omitempty"` // Messages acknowledged by the client
+	Before     int      `json:"before,omitempty"`      // Chat history cursor: load messages older than this ID
+	Limit      int      `json:"limit,omitempty"`       // Chat history page size
+	PostIDs    []int    `json:"post_ids,omitempty"`    // Posts to (un)subscribe to the comments of
 }
 
 type PageData struct {
@@ -92,8 +146,9 @@ type RegisterRequest struct {
 }
 
 type RegisterResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"` // Fields refused by validation
 }
 
 type LoginRequest struct {
@@ -107,5 +162,8 @@ type LoginResponse struct {
 }
 
 type Response struct {
-	Username string `json:"username"`
+	UserID        int    `json:"userId"`
+	Username      string `json:"username"`
+	EmailVerified bool   `json:"emailVerified"`
+	CanModerate   bool   `json:"canModerate"` // May edit and delete anybody's posts and comments
 }
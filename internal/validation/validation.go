@@ -0,0 +1,180 @@
+// Package validation checks what users type in forms before it reaches the
+// database. A Validator collects one FieldError per refused field, so the
+// frontend can show every problem at once.
+package validation
+
+import (
+	"config"
+	"fmt"
+	"models"
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Codes of the field errors
+const (
+	CodeRequired     = "required"
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeInvalid      = "invalid"
+	CodeInvalidChars = "invalid_characters"
+	CodeWeak         = "weak"
+)
+
+// Limits that aren't a matter of policy
+const (
+	nameMaxLength     = 50
+	emailMaxLength    = 254
+	passwordMaxLength = 72 // bcrypt ignores anything longer
+	titleMaxLength    = 200
+	contentMaxLength  = 10000
+)
+
+// Genders a user can pick
+var Genders = []string{"Male", "Female", "Other"}
+
+// Validator collects the errors of one form
+type Validator struct {
+	Errors []models.FieldError
+}
+
+// Valid reports whether no field was refused
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// Add refuses a field. Only the first problem of each field is kept.
+func (v *Validator) Add(field, code, message string) {
+	for _, e := range v.Errors {
+		if e.Field == field {
+			return
+		}
+	}
+	v.Errors = append(v.Errors, models.FieldError{Field: field, Code: code, Message: message})
+}
+
+// length checks that a value is present and at most max characters long,
+// and reports whether it was
+func (v *Validator) length(field, label, value string, max int) bool {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, CodeRequired, label+" is required")
+		return false
+	}
+	if utf8.RuneCountInString(value) > max {
+		v.Add(field, CodeTooLong, fmt.Sprintf("%s must be at most %d characters long", label, max))
+		return false
+	}
+	return true
+}
+
+// Name checks a first or last name
+func (v *Validator) Name(field, label, value string) {
+	if !v.length(field, label, value, nameMaxLength) {
+		return
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			v.Add(field, CodeInvalidChars, label+" contains invalid characters")
+			return
+		}
+	}
+}
+
+// Title checks the title of a post
+func (v *Validator) Title(field, value string) {
+	v.length(field, "Title", value, titleMaxLength)
+}
+
+// Content checks the text of a post or comment
+func (v *Validator) Content(field, value string) {
+	v.length(field, "Content", value, contentMaxLength)
+}
+
+// nicknameChars is built from config.NICKNAME_CHARSET on first use, once
+// the configuration is loaded
+var (
+	nicknameChars     *regexp.Regexp
+	nicknameCharsOnce sync.Once
+)
+
+// Nickname checks a nickname against the length and charset policy
+func (v *Validator) Nickname(field, value string) {
+	if !v.length(field, "Username", value, config.NICKNAME_MAX_LENGTH) {
+		return
+	}
+	if utf8.RuneCountInString(value) < config.NICKNAME_MIN_LENGTH {
+		v.Add(field, CodeTooShort, fmt.Sprintf("Username must be at least %d characters long", config.NICKNAME_MIN_LENGTH))
+		return
+	}
+
+	nicknameCharsOnce.Do(func() {
+		nicknameChars = regexp.MustCompile(`^(?:` + config.NICKNAME_CHARSET + `)+$`)
+	})
+	// Logins are a nickname or an email, a nickname must never pass for one
+	if !nicknameChars.MatchString(value) || strings.Contains(value, "@") {
+		v.Add(field, CodeInvalidChars, "Username contains characters that aren't allowed")
+	}
+}
+
+// Email checks that an email address is well formed
+func (v *Validator) Email(field, value string) {
+	if !v.length(field, "Email", value, emailMaxLength) {
+		return
+	}
+	// ParseAddress also accepts "Name <address>", only keep bare addresses
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value || !strings.Contains(value[strings.LastIndex(value, "@"):], ".") {
+		v.Add(field, CodeInvalid, "Email address is not valid")
+	}
+}
+
+// Gender checks that the gender is one of Genders
+func (v *Validator) Gender(field, value string) {
+	for _, g := range Genders {
+		if value == g {
+			return
+		}
+	}
+	if value == "" {
+		v.Add(field, CodeRequired, "Gender is required")
+		return
+	}
+	v.Add(field, CodeInvalid, "Gender must be one of "+strings.Join(Genders, ", "))
+}
+
+// Password checks a new password against the password policy
+func (v *Validator) Password(field, value string) {
+	if value == "" {
+		v.Add(field, CodeRequired, "Password is required")
+		return
+	}
+	if utf8.RuneCountInString(value) < config.PASSWORD_MIN_LENGTH {
+		v.Add(field, CodeTooShort, fmt.Sprintf("Password must be at least %d characters long", config.PASSWORD_MIN_LENGTH))
+		return
+	}
+	if len(value) > passwordMaxLength {
+		v.Add(field, CodeTooLong, fmt.Sprintf("Password must be at most %d bytes long", passwordMaxLength))
+		return
+	}
+
+	var lower, upper, digit, symbol int
+	for _, r := range value {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	if lower+upper+digit+symbol < config.PASSWORD_MIN_CLASSES {
+		v.Add(field, CodeWeak, fmt.Sprintf("Password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", config.PASSWORD_MIN_CLASSES))
+	}
+}
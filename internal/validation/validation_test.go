@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+// check runs one rule on a value and returns the code of the error it
+// added, "" if the value passed
+func check(rule func(v *Validator, value string), value string) string {
+	var v Validator
+	rule(&v, value)
+	if v.Valid() {
+		return ""
+	}
+	return v.Errors[0].Code
+}
+
+type ruleTest struct {
+	value string
+	want  string
+}
+
+func runRule(t *testing.T, name string, rule func(v *Validator, value string), tests []ruleTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		if got := check(rule, tt.value); got != tt.want {
+			t.Errorf("%s(%q) = %q, want %q", name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	runRule(t, "Name", func(v *Validator, value string) { v.Name("firstname", "First name", value) }, []ruleTest{
+		{"Ada", ""},
+		{"Jean-Édouard", ""},
+		{"", CodeRequired},
+		{"   ", CodeRequired},
+		{strings.Repeat("a", nameMaxLength+1), CodeTooLong},
+		{"Ada\nLovelace", CodeInvalidChars},
+	})
+}
+
+func TestNickname(t *testing.T) {
+	runRule(t, "Nickname", func(v *Validator, value string) { v.Nickname("username", value) }, []ruleTest{
+		{"ada_92", ""},
+		{"a.b-c", ""},
+		{"", CodeRequired},
+		{"ab", CodeTooShort},
+		{strings.Repeat("a", 21), CodeTooLong},
+		{"ada lovelace", CodeInvalidChars},
+		{"ada@home", CodeInvalidChars},
+		{"adä", CodeInvalidChars},
+	})
+}
+
+func TestEmail(t *testing.T) {
+	runRule(t, "Email", func(v *Validator, value string) { v.Email("email", value) }, []ruleTest{
+		{"ada@example.com", ""},
+		{"", CodeRequired},
+		{"ada", CodeInvalid},
+		{"ada@localhost", CodeInvalid},
+		{"Ada <ada@example.com>", CodeInvalid},
+		{strings.Repeat("a", emailMaxLength) + "@example.com", CodeTooLong},
+	})
+}
+
+func TestGender(t *testing.T) {
+	runRule(t, "Gender", func(v *Validator, value string) { v.Gender("gender", value) }, []ruleTest{
+		{"Male", ""},
+		{"Female", ""},
+		{"Other", ""},
+		{"", CodeRequired},
+		{"male", CodeInvalid},
+	})
+}
+
+func TestPassword(t *testing.T) {
+	runRule(t, "Password", func(v *Validator, value string) { v.Password("password", value) }, []ruleTest{
+		{"Passw0rd", ""},
+		{"password1", ""},
+		{"", CodeRequired},
+		{"Pa1", CodeTooShort},
+		{strings.Repeat("a1", 37), CodeTooLong},
+		{"password", CodeWeak},
+		{"12345678", CodeWeak},
+	})
+}
+
+func TestAddKeepsTheFirstErrorOfEachField(t *testing.T) {
+	var v Validator
+	v.Add("email", CodeRequired, "first")
+	v.Add("email", CodeInvalid, "second")
+	v.Add("password", CodeWeak, "third")
+
+	if len(v.Errors) != 2 {
+		t.Fatalf("errors = %+v, want one per field", v.Errors)
+	}
+	if v.Errors[0].Message != "first" {
+		t.Errorf("email error = %q, want the first one", v.Errors[0].Message)
+	}
+	if v.Valid() {
+		t.Error("Valid() with errors")
+	}
+}
+
+func TestTitleAndContent(t *testing.T) {
+	runRule(t, "Title", func(v *Validator, value string) { v.Title("title", value) }, []ruleTest{
+		{"Hello", ""},
+		{"", CodeRequired},
+		{" \t", CodeRequired},
+		{strings.Repeat("é", titleMaxLength), ""},
+		{strings.Repeat("a", titleMaxLength+1), CodeTooLong},
+	})
+	runRule(t, "Content", func(v *Validator, value string) { v.Content("content", value) }, []ruleTest{
+		{"Some text", ""},
+		{"\n\n", CodeRequired},
+		{strings.Repeat("a", contentMaxLength+1), CodeTooLong},
+	})
+}
@@ -0,0 +1,170 @@
+import { createWelcomePage } from "../welcome.js";
+import { showMessage, showFieldErrors } from "./register.js";
+
+// Builds a labelled input like the ones of the login and registration forms
+function createField(labelText, id, type) {
+    const group = document.createElement('div');
+    group.style.marginBottom = '1rem';
+
+    const label = document.createElement('label');
+    label.textContent = labelText;
+    label.htmlFor = id;
+    label.style.display = 'block';
+    label.style.marginBottom = '0.5rem';
+    label.style.fontWeight = 'bold';
+
+    const input = document.createElement('input');
+    input.type = type;
+    input.id = id;
+    input.name = id;
+    input.required = true;
+    input.style.width = '100%';
+    input.style.padding = '0.75rem';
+    input.style.border = '1px solid #ddd';
+    input.style.borderRadius = '4px';
+    input.style.fontSize = '1rem';
+    input.style.boxSizing = 'border-box';
+
+    group.appendChild(label);
+    group.appendChild(input);
+    return group;
+}
+
+// Builds a button of the form, with its hover color
+function createButton(text, color, hoverColor) {
+    const button = document.createElement('button');
+    button.type = 'button';
+    button.textContent = text;
+    button.style.flex = '1';
+    button.style.padding = '0.75rem';
+    button.style.border = 'none';
+    button.style.borderRadius = '4px';
+    button.style.fontSize = '1rem';
+    button.style.cursor = 'pointer';
+    button.style.backgroundColor = color;
+    button.style.color = 'white';
+    button.addEventListener('mouseover', () => {
+        button.style.backgroundColor = hoverColor;
+    });
+    button.addEventListener('mouseout', () => {
+        button.style.backgroundColor = color;
+    });
+    return button;
+}
+
+// Replaces the content of the login container with a form: its fields,
+// a submit and a back button, and the message div
+function replaceLoginContainer(title, fields, submitText, onSubmit) {
+    const loginContainer = document.querySelector('.login-container');
+    if (!loginContainer) return;
+
+    loginContainer.innerHTML = '';
+
+    const heading = document.createElement('h2');
+    heading.textContent = title;
+    loginContainer.appendChild(heading);
+
+    const form = document.createElement('form');
+    fields.forEach(field => form.appendChild(field));
+
+    const buttonGroup = document.createElement('div');
+    buttonGroup.style.display = 'flex';
+    buttonGroup.style.gap = '1rem';
+    buttonGroup.style.marginTop = '1.5rem';
+
+    const submitButton = createButton(submitText, '#3498db', '#2980b9');
+    const backButton = createButton('Back to Login', '#95a5a6', '#7f8c8d');
+    buttonGroup.appendChild(submitButton);
+    buttonGroup.appendChild(backButton);
+    form.appendChild(buttonGroup);
+
+    const messageDiv = document.createElement('div');
+    messageDiv.id = 'message';
+    messageDiv.className = 'message';
+    messageDiv.style.display = 'none';
+    messageDiv.style.marginTop = '1rem';
+
+    loginContainer.appendChild(form);
+    loginContainer.appendChild(messageDiv);
+
+    submitButton.addEventListener('click', () => onSubmit(submitButton));
+    backButton.addEventListener('click', () => createWelcomePage());
+}
+
+// Sends a JSON body and returns the success and message of the answer,
+// whether it is a success or an error
+async function postJSON(url, data) {
+    const response = await fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(data),
+    });
+    const result = await response.json();
+    return {
+        success: response.ok && result.success,
+        message: result.message || result.error,
+        errors: result.errors,
+    };
+}
+
+// Shows the form asking for the account whose password was forgotten
+export function replaceWithResetRequestForm() {
+    const loginField = createField('Username or Email', 'reset-login', 'text');
+
+    replaceLoginContainer('Reset your Password', [loginField], 'Send Link', async (button) => {
+        const login = document.getElementById('reset-login').value;
+        if (!login) {
+            showMessage('Please enter your username or email', false);
+            return;
+        }
+
+        button.disabled = true;
+        try {
+            const result = await postJSON('/api/password-reset', { login });
+            showMessage(result.message, result.success);
+        } catch (error) {
+            console.error('Error:', error);
+            showMessage('Request failed. Try again later.', false);
+        } finally {
+            button.disabled = false;
+        }
+    });
+}
+
+// Shows the form choosing a new password, opened from the emailed link
+export function replaceWithResetConfirmForm(token) {
+    const passwordField = createField('New Password', 'new-password', 'password');
+    const confirmField = createField('Confirm Password', 'confirm-new-password', 'password');
+
+    replaceLoginContainer('Choose a new Password', [passwordField, confirmField], 'Save', async (button) => {
+        const password = document.getElementById('new-password').value;
+        const confirmPassword = document.getElementById('confirm-new-password').value;
+
+        if (!password || !confirmPassword) {
+            showMessage('Please fill in all fields', false);
+            return;
+        }
+        if (password !== confirmPassword) {
+            showMessage("Passwords don't match", false);
+            return;
+        }
+
+        button.disabled = true;
+        try {
+            const result = await postJSON('/api/password-reset/confirm', { token, password });
+            showMessage(result.message, result.success);
+            // The policy errors are about the password field
+            showFieldErrors((result.errors || []).map(error => ({ ...error, field: 'new-password' })));
+
+            // Back to the login form once the new password is saved
+            if (result.success) {
+                setTimeout(() => createWelcomePage(), 3000);
+                return;
+            }
+        } catch (error) {
+            console.error('Error:', error);
+            showMessage('Reset failed. Try again later.', false);
+        }
+        button.disabled = false;
+    });
+}
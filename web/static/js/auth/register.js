@@ -13,6 +13,35 @@ export function showMessage(text, isSuccess) {
     }
 }
 
+// Outlines the fields the server refused and lists why under the message;
+// called without errors it clears the previous ones
+export function showFieldErrors(errors = []) {
+    document.querySelectorAll('.login-container input, .login-container select').forEach(input => {
+        input.style.borderColor = '#ddd';
+    });
+    if (!errors || errors.length === 0) return;
+
+    errors.forEach(error => {
+        const input = document.getElementById(error.field);
+        if (input) {
+            input.style.borderColor = '#e74c3c';
+        }
+    });
+
+    const messageDiv = document.getElementById('message');
+    if (messageDiv) {
+        const list = document.createElement('ul');
+        list.style.margin = '0.5rem 0 0';
+        list.style.paddingLeft = '1.2rem';
+        errors.forEach(error => {
+            const item = document.createElement('li');
+            item.textContent = error.message;
+            list.appendChild(item);
+        });
+        messageDiv.appendChild(list);
+    }
+}
+
 // Function to replace login form with registration form
 export function replaceWithRegistrationForm() {
     // Get the login container
@@ -146,8 +175,13 @@ export function replaceWithRegistrationForm() {
     genderLabel.style.marginBottom = '0.5rem';
     genderLabel.style.fontWeight = 'bold';
     
-    const genderInput = document.createElement('input');
-    genderInput.type = 'text';
+    const genderInput = document.createElement('select');
+    ['', 'Male', 'Female', 'Other'].forEach(value => {
+        const option = document.createElement('option');
+        option.value = value;
+        option.textContent = value || 'Select...';
+        genderInput.appendChild(option);
+    });
     genderInput.id = 'gender';
     genderInput.name = 'gender';
     genderInput.required = true;
@@ -322,6 +356,7 @@ export function replaceWithRegistrationForm() {
         // Await for the response of the golang server and show the message
         const result = await response.json();
         showMessage(result.message, result.success);
+        showFieldErrors(result.errors);
 
         // If the success response if true, send the user to the main page after a short delay
         if (result.success) {
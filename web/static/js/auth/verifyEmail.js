@@ -0,0 +1,57 @@
+// Confirms the email address from the token of the emailed link
+export async function verifyEmailFromLink(token) {
+    try {
+        const response = await fetch('/api/verify-email', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ token }),
+        });
+        const result = await response.json();
+        alert(result.message || result.error);
+    } catch (error) {
+        console.error('Email verification error:', error);
+        alert('Email verification failed. Try again later.');
+    }
+}
+
+// Builds the bar reminding an unverified user to open the link, with a
+// button to get a new one
+export function createVerificationBanner() {
+    const banner = document.createElement('div');
+    banner.id = 'verificationBanner';
+    banner.style.backgroundColor = '#fff3cd';
+    banner.style.color = '#856404';
+    banner.style.padding = '0.75rem';
+    banner.style.textAlign = 'center';
+    banner.style.borderBottom = '1px solid #ffeeba';
+
+    const text = document.createElement('span');
+    text.textContent = 'Please verify your email address with the link we sent you. ';
+    banner.appendChild(text);
+
+    const resendButton = document.createElement('button');
+    resendButton.textContent = 'Send a new link';
+    resendButton.style.marginLeft = '0.5rem';
+    resendButton.style.padding = '0.25rem 0.75rem';
+    resendButton.style.border = 'none';
+    resendButton.style.borderRadius = '4px';
+    resendButton.style.cursor = 'pointer';
+    resendButton.style.backgroundColor = '#856404';
+    resendButton.style.color = 'white';
+    banner.appendChild(resendButton);
+
+    resendButton.addEventListener('click', async () => {
+        resendButton.disabled = true;
+        try {
+            const response = await fetch('/api/verify-email/resend', { method: 'POST' });
+            const result = await response.json();
+            text.textContent = (result.message || result.error) + ' ';
+        } catch (error) {
+            console.error('Resend verification error:', error);
+            text.textContent = 'Could not send a new link, try again later. ';
+        }
+        resendButton.disabled = false;
+    });
+
+    return banner;
+}
@@ -1,4 +1,5 @@
 import { fetchPostComments } from "./fetch/forum.js";
+import { canModify, actionButton, updateEditedMarker, showUndo } from "./editing.js";
 
 export async function populateCommentList(postId) {
     const commentList = document.getElementById(`commentList-${postId}`);
@@ -6,49 +7,192 @@ export async function populateCommentList(postId) {
 
     try {
         const comments = await fetchPostComments(postId);
+        // Placeholders of deleted comments don't count
+        setCommentCount(postId, comments ? comments.filter(comment => !comment.Deleted).length : 0);
         
-        // Add debugging
-        console.log('Fetched comments:', comments);
-
         if (!comments || comments.length === 0) {
             const li = document.createElement('li');
             li.textContent = 'No comments available';
+            li.dataset.placeholder = 'true';
             commentList.appendChild(li);
             return;
         }
-        comments.forEach(comment => {
-            const li = document.createElement('li');
-            li.style.border = '1px solid #ddd';
-            li.style.marginBottom = '1rem';
-            li.style.padding = '1rem';
-            li.style.borderRadius = '4px';
+        comments.forEach(comment => renderComment(commentList, comment));
+    } catch (error) {
+        console.error('Error fetching comments:', error);
+        const li = document.createElement('li');
+        li.textContent = `Error loading comments: ${error.message}`;
+        commentList.appendChild(li);
+    }
+}
 
-            const content = document.createElement('p');
-            content.textContent = comment.Body || 'No content';
+function renderComment(commentList, comment) {
+    commentList.appendChild(buildComment(comment));
+}
 
-            const date = new Date(comment.CreatedAt);
-            console.log("comment: ", comment);
+function buildComment(comment) {
+    const li = document.createElement('li');
+    li.dataset.commentId = comment.ID;
+    li.style.border = '1px solid #ddd';
+    li.style.marginBottom = '1rem';
+    li.style.padding = '1rem';
+    li.style.borderRadius = '4px';
 
-            console.log("date: ", date);
+    const content = document.createElement('p');
+    content.className = 'comment-body';
+    content.textContent = comment.Body || 'No content';
 
-            const formattedDate = date.getFullYear() + ' ' + 
-                String(date.getMonth() + 1).padStart(2, '0') + ' ' + 
-                String(date.getDate()).padStart(2, '0');
+    // A deleted comment keeps its place, nothing else
+    if (comment.Deleted) {
+        li.dataset.deleted = 'true';
+        content.style.fontStyle = 'italic';
+        content.style.color = '#888';
+        li.appendChild(content);
+        return li;
+    }
 
-            const metadata = document.createElement('small');
-            metadata.textContent = `By: ${comment.Username} | Date: ${formattedDate}`;
+    const date = new Date(comment.CreatedAt);
 
-            li.appendChild(content);
-            li.appendChild(metadata);
+    const formattedDate = date.getFullYear() + ' ' + 
+        String(date.getMonth() + 1).padStart(2, '0') + ' ' + 
+        String(date.getDate()).padStart(2, '0');
 
-            commentList.appendChild(li);
+    const metadata = document.createElement('small');
+    metadata.textContent = `By: ${comment.Username} | Date: ${formattedDate}`;
+    const edited = document.createElement('span');
+    edited.className = 'comment-edited';
+    metadata.appendChild(edited);
+    updateEditedMarker(edited, commentUrl(comment) + '/revisions', comment.Revisions);
+    if (canModify(comment.UserID)) {
+        metadata.appendChild(actionButton('Edit', () => editComment(li, comment)));
+        metadata.appendChild(actionButton('Delete', () => deleteComment(comment)));
+    }
+
+    li.appendChild(content);
+    li.appendChild(metadata);
+
+    return li;
+}
+
+// Adds a comment pushed by the server to its post, if the post is shown and
+// doesn't have it yet
+export function receiveComment(comment) {
+    const commentList = document.getElementById(`commentList-${comment.PostID}`);
+    if (!commentList || commentList.querySelector(`[data-comment-id="${comment.ID}"]`)) return;
+
+    commentList.querySelectorAll('[data-placeholder]').forEach(li => li.remove());
+    renderComment(commentList, comment);
+
+    const count = document.getElementById(`commentCount-${comment.PostID}`);
+    if (count) setCommentCount(comment.PostID, Number(count.dataset.count) + 1);
+}
+
+// Shows the new version of an edited comment, if it's shown
+export function receiveCommentUpdate(comment) {
+    const li = findComment(comment);
+    if (!li) return;
+
+    li.querySelector('.comment-body').textContent = comment.Body || 'No content';
+    updateEditedMarker(li.querySelector('.comment-edited'), commentUrl(comment) + '/revisions', comment.Revisions);
+}
+
+// Replaces a deleted comment with its placeholder
+export function removeComment(comment) {
+    const li = findComment(comment);
+    if (!li || li.dataset.deleted) return;
+
+    li.replaceWith(buildComment({ ...comment, Deleted: true, Body: '[deleted]' }));
+    const count = document.getElementById(`commentCount-${comment.PostID}`);
+    if (count) setCommentCount(comment.PostID, Math.max(0, Number(count.dataset.count) - 1));
+}
+
+// Puts a restored comment back in place of its placeholder
+export function receiveCommentRestore(comment) {
+    const li = findComment(comment);
+    if (!li) {
+        receiveComment(comment);
+        return;
+    }
+    if (!li.dataset.deleted) return;
+
+    li.replaceWith(buildComment(comment));
+    const count = document.getElementById(`commentCount-${comment.PostID}`);
+    if (count) setCommentCount(comment.PostID, Number(count.dataset.count) + 1);
+}
+
+function findComment(comment) {
+    return document.querySelector(`#commentList-${comment.PostID} [data-comment-id="${comment.ID}"]`);
+}
+
+function commentUrl(comment) {
+    return `/api/posts/${comment.PostID}/comments/${comment.ID}`;
+}
+
+// Replaces the body of a comment with a form to edit it
+function editComment(li, comment) {
+    const content = li.querySelector('.comment-body');
+    if (li.querySelector('.comment-edit-form')) return;
+
+    const form = document.createElement('div');
+    form.className = 'comment-edit-form';
+    const bodyInput = document.createElement('input');
+    bodyInput.type = 'text';
+    bodyInput.value = content.textContent;
+    bodyInput.style.width = '100%';
+
+    const close = () => {
+        form.remove();
+        content.style.display = '';
+    };
+    const save = actionButton('Save', async () => {
+        const response = await fetch(commentUrl(comment), {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ body: bodyInput.value.trim() }),
         });
-    } catch (error) {
-        console.error('Error fetching comments:', error);
-        const li = document.createElement('li');
-        li.textContent = `Error loading comments: ${error.message}`;
-        commentList.appendChild(li);
+        const result = await response.json();
+        if (!response.ok) {
+            alert(result.error || result.errors?.[0]?.message || 'Failed to edit comment');
+            return;
+        }
+        close();
+        receiveCommentUpdate(result);
+    });
+
+    form.appendChild(bodyInput);
+    form.appendChild(save);
+    form.appendChild(actionButton('Cancel', close));
+    content.style.display = 'none';
+    content.after(form);
+}
+
+async function deleteComment(comment) {
+    if (!confirm('Delete this comment?')) return;
+
+    const response = await fetch(commentUrl(comment), { method: 'DELETE' });
+    if (!response.ok) {
+        const result = await response.json().catch(() => ({}));
+        alert(result.error || 'Failed to delete comment');
+        return;
     }
+    removeComment(comment);
+    showUndo('Comment deleted', async () => {
+        const restored = await fetch(commentUrl(comment) + '/restore', { method: 'POST' });
+        const result = await restored.json();
+        if (!restored.ok) {
+            alert(result.error || 'Failed to restore comment');
+            return;
+        }
+        receiveCommentRestore(result);
+    });
+}
+
+// Updates the number of comments shown under a post
+export function setCommentCount(postId, n) {
+    const count = document.getElementById(`commentCount-${postId}`);
+    if (!count) return;
+    count.dataset.count = n;
+    count.textContent = ` | ${n} comment${n === 1 ? '' : 's'}`;
 }
 
 export function setupCommentCreation(postId) {
@@ -0,0 +1,90 @@
+// Shared pieces for editing and deleting posts and comments
+
+// The logged-in user, to know which posts and comments they may change
+let viewer = { id: 0, canModerate: false };
+
+export function setViewer(user) {
+    viewer = { id: user.userId || 0, canModerate: !!user.canModerate };
+}
+
+// Same rule as the server: the author, or a moderator
+export function canModify(authorId) {
+    return (viewer.id !== 0 && viewer.id === authorId) || viewer.canModerate;
+}
+
+export function actionButton(text, onClick) {
+    const button = document.createElement('button');
+    button.textContent = text;
+    button.style.padding = '0.25rem 0.5rem';
+    button.style.marginLeft = '0.5rem';
+    button.addEventListener('click', onClick);
+    return button;
+}
+
+// Fills a marker with "(edited)" when there are earlier versions; clicking
+// it shows them below the marker, fetched from revisionsUrl
+export function updateEditedMarker(marker, revisionsUrl, count) {
+    marker.innerHTML = '';
+    if (!count) return;
+
+    const link = document.createElement('a');
+    link.href = '#';
+    link.textContent = ' (edited)';
+    const history = document.createElement('ul');
+    history.style.display = 'none';
+    history.style.fontSize = '0.85em';
+    history.style.color = '#666';
+
+    link.addEventListener('click', async (event) => {
+        event.preventDefault();
+        if (history.style.display !== 'none') {
+            history.style.display = 'none';
+            return;
+        }
+        try {
+            const response = await fetch(revisionsUrl);
+            if (!response.ok) throw new Error(await response.text());
+            const revisions = await response.json();
+
+            history.innerHTML = '';
+            revisions.forEach(revision => {
+                const li = document.createElement('li');
+                const date = new Date(revision.edited_at).toLocaleString();
+                const title = revision.title ? `${revision.title}: ` : '';
+                li.textContent = `${title}${revision.body} (replaced by ${revision.edited_by}, ${date})`;
+                history.appendChild(li);
+            });
+            history.style.display = '';
+        } catch (error) {
+            console.error('Error fetching revisions:', error);
+        }
+    });
+
+    marker.appendChild(link);
+    marker.appendChild(history);
+}
+
+// Shows for a few seconds that something was deleted, with a button to
+// bring it back
+export function showUndo(text, onUndo) {
+    document.getElementById('undoToast')?.remove();
+
+    const toast = document.createElement('div');
+    toast.id = 'undoToast';
+    toast.textContent = text;
+    toast.style.position = 'fixed';
+    toast.style.bottom = '1rem';
+    toast.style.left = '1rem';
+    toast.style.padding = '0.5rem 1rem';
+    toast.style.background = '#333';
+    toast.style.color = 'white';
+    toast.style.borderRadius = '4px';
+
+    const timer = setTimeout(() => toast.remove(), 8000);
+    toast.appendChild(actionButton('Undo', () => {
+        clearTimeout(timer);
+        toast.remove();
+        onUndo();
+    }));
+    document.body.appendChild(toast);
+}
@@ -1,6 +1,11 @@
-export async function fetchPosts() {
+// Fetches a page of the feed: { posts, next_cursor, prev_cursor, has_more }.
+// Pass the next_cursor of a page as before to get the one below it.
+export async function fetchPosts({ category = '', sort = 'newest', before = '' } = {}) {
     try {
-        const response = await fetch('/api/posts');
+        const params = new URLSearchParams({ sort });
+        if (category) params.set('category', category);
+        if (before) params.set('before', before);
+        const response = await fetch(`/api/posts?${params}`);
         
         if (!response.ok) {
             const errorText = await response.text();
@@ -14,6 +19,23 @@ export async function fetchPosts() {
     }
 }
 
+// Fetches the categories with their number of posts
+export async function fetchCategories() {
+    try {
+        const response = await fetch('/api/categories');
+
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`Failed to fetch categories: ${errorText}`);
+        }
+
+        return await response.json();
+    } catch (error) {
+        console.error('Error in fetchCategories:', error);
+        throw error;
+    }
+}
+
 // This should be added to or updated in your forum.js file
 export async function fetchPostComments(postId) {
     try {
@@ -2,6 +2,8 @@ import { createWelcomePage, removeWelcomePage } from './welcome.js';
 import { populateUserList } from './user_list.js';
 import { populatePostList, setupPostCreation } from './posts.js';
 import { initializePrivateMessaging } from './private_message.js';
+import { createVerificationBanner } from './auth/verifyEmail.js';
+import { setViewer } from './editing.js';
 
 // Add this at the top of the file - Demo mode detection
 const IS_DEMO_MODE = window.location.hostname.includes('render.com') || 
@@ -217,6 +219,9 @@ export async function createMainPage() {
   
   // Append to body
   document.body.appendChild(header);
+  if (!user.emailVerified) {
+    document.body.appendChild(createVerificationBanner());
+  }
   document.body.appendChild(contentWrapper);
   document.body.appendChild(footer);
   
@@ -237,6 +242,7 @@ export async function createMainPage() {
   }
   
   // Initialize everything after DOM elements are created
+  setViewer(user);
   initializePage();
   return user.username
 }
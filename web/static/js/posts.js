@@ -1,140 +1,406 @@
-import { fetchPosts } from './fetch/forum.js';
-import { populateCommentList, setupCommentCreation } from './comment.js';
+import { fetchPosts, fetchCategories } from './fetch/forum.js';
+import { populateCommentList, setupCommentCreation, setCommentCount } from './comment.js';
+import { getSocket } from './websockets.js';
+import { canModify, actionButton, updateEditedMarker, showUndo } from './editing.js';
+
+// Category the post list is filtered on, '' for all of them
+let selectedCategory = '';
+
+// Order of the post list: newest, comments or active
+let selectedSort = 'newest';
+
+// Cursor of the next page, '' once the last one is shown
+let nextCursor = '';
+let loadingPage = false;
+// Bumped on every reload so that a page still in flight for an older list
+// is dropped
+let listVersion = 0;
+
+// Posts shown in the list, whose new comments the server pushes to us
+const openPosts = new Set();
+
+// Watches the end of the list to load the next page when it comes into view
+const pageObserver = new IntersectionObserver(entries => {
+    if (entries.some(entry => entry.isIntersecting)) loadNextPage();
+});
 
 export async function populatePostList() {
     const postList = document.getElementById('postList');
     postList.innerHTML = ''; // Clear existing posts
+    getSocket()?.unsubscribePosts?.([...openPosts]);
+    openPosts.clear();
+    nextCursor = '';
+    loadingPage = false;
+    listVersion++;
+
+    await loadNextPage(true);
+
+    // Sentinel after the list, observed once
+    let sentinel = document.getElementById('postListEnd');
+    if (!sentinel) {
+        sentinel = document.createElement('div');
+        sentinel.id = 'postListEnd';
+        postList.parentNode.insertBefore(sentinel, postList.nextSibling);
+        pageObserver.observe(sentinel);
+    }
+}
+
+// Appends the next page of posts to the list
+async function loadNextPage(first = false) {
+    if (loadingPage || (!first && !nextCursor)) return;
+    loadingPage = true;
+    const version = listVersion;
+    const postList = document.getElementById('postList');
 
     try {
-        const posts = await fetchPosts();
-        
-        // Add debugging
-        // console.log('Fetched posts:', posts);
-        
-        if (!posts || posts.length === 0) {
+        const page = await fetchPosts({ category: selectedCategory, sort: selectedSort, before: nextCursor });
+        if (version !== listVersion) return;
+
+        if (first && page.posts.length === 0) {
             const li = document.createElement('li');
             li.textContent = 'No posts available';
+            li.dataset.placeholder = 'true';
             postList.appendChild(li);
-            return;
         }
-
-        posts.forEach(post => {
-            const li = document.createElement('li');
-            li.style.border = '1px solid #ddd';
-            li.style.marginBottom = '1rem';
-            li.style.padding = '1rem';
-            li.style.borderRadius = '4px';
-            
-            const title = document.createElement('h3');
-            title.textContent = post.Title || 'Untitled Post';
-            
-            const content = document.createElement('p');
-            content.textContent = post.Body || 'No content';
-
-            const date = new Date(post.CreatedAt);
-            console.log("post: ", post);
-            
-            console.log("date: ", date);
-            
-            const formattedDate = date.getFullYear() + ' ' + 
-                String(date.getMonth() + 1).padStart(2, '0') + ' ' + 
-                String(date.getDate()).padStart(2, '0');
-            
-            const metadata = document.createElement('small');
-            metadata.textContent = `By: ${post.Username} | Date: ${formattedDate}`;
-            
-            li.appendChild(title);
-            li.appendChild(content);
-            li.appendChild(metadata);
-
-            // Create comment section
-            const commentSection = document.createElement('div');
-            commentSection.style.marginTop = '1rem';
-            commentSection.style.padding = '1rem';
-            commentSection.style.borderTop = '1px solid #ddd';
-
-            const commentTitle = document.createElement('h4');
-            commentTitle.textContent = 'Comments';
-            commentSection.appendChild(commentTitle);
-
-            const commentList = document.createElement('ul');
-            commentList.id = `commentList-${post.ID}`;
-            commentList.style.listStyleType = 'none';
-            commentList.style.padding = '0';
-            commentSection.appendChild(commentList);
-
-            // Add comment input
-            const commentInputContainer = document.createElement('div');
-            commentInputContainer.style.marginTop = '1rem';
-
-            const commentInput = document.createElement('input');
-            commentInput.type = 'text';
-            commentInput.id = `newCommentBody-${post.ID}`;
-            commentInput.placeholder = 'Add a comment...';
-            commentInput.style.width = '100%';
-            commentInput.style.padding = '0.5rem';
-            commentInput.style.marginBottom = '0.5rem';
-
-            const submitCommentButton = document.createElement('button');
-            submitCommentButton.textContent = 'Comment';
-            submitCommentButton.id = `newCommentButton-${post.ID}`;
-            submitCommentButton.style.padding = '0.5rem 1rem';
-
-            commentInputContainer.appendChild(commentInput);
-            commentInputContainer.appendChild(submitCommentButton);
-            commentSection.appendChild(commentInputContainer);
-
-            li.appendChild(commentSection);
-            postList.appendChild(li);
-
-            // Populate comments and setup comment creation
-            populateCommentList(post.ID);
-            setupCommentCreation(post.ID);
-        });
+        page.posts.forEach(post => renderPost(postList, post));
+        getSocket()?.subscribePosts?.(page.posts.map(post => post.ID));
+        nextCursor = page.has_more ? page.next_cursor : '';
     } catch (error) {
+        if (version !== listVersion) return;
         console.error('Error fetching posts:', error);
         const li = document.createElement('li');
         li.textContent = `Error loading posts: ${error.message}`;
         postList.appendChild(li);
+        nextCursor = '';
+    } finally {
+        if (version === listVersion) loadingPage = false;
+    }
+}
+
+// Asks again for the comments of every shown post, e.g. after reconnecting
+export function followOpenPosts() {
+    getSocket()?.subscribePosts?.([...openPosts]);
+}
+
+// Adds a post pushed by the server (new or restored) to the top of the list
+// when it belongs there, i.e. it's in the selected category and the list
+// isn't sorted by number of comments
+export function receivePost(post) {
+    populateCategories();
+
+    const postList = document.getElementById('postList');
+    if (!postList || openPosts.has(post.ID) || selectedSort === 'comments') return;
+    if (selectedCategory && !(post.Categories || []).includes(selectedCategory)) return;
+
+    postList.querySelectorAll('[data-placeholder]').forEach(li => li.remove());
+    renderPost(postList, post, true);
+    getSocket()?.subscribePosts?.([post.ID]);
+}
+
+// Shows the new version of an edited post, if it's in the list
+export function receivePostUpdate(post) {
+    const title = document.getElementById(`postTitle-${post.ID}`);
+    const content = document.getElementById(`postBody-${post.ID}`);
+    if (!title || !content) return;
+
+    title.textContent = post.Title || 'Untitled Post';
+    content.textContent = post.Body || 'No content';
+    updateEditedMarker(document.getElementById(`postEdited-${post.ID}`),
+        `/api/posts/${post.ID}/revisions`, post.Revisions);
+}
+
+// Takes a deleted post out of the list
+export function removePost(postId) {
+    document.getElementById(`post-${postId}`)?.remove();
+    if (openPosts.delete(postId)) {
+        getSocket()?.unsubscribePosts?.([postId]);
+        populateCategories();
     }
 }
 
+// Replaces the title and body of a post with a form to edit them
+function editPost(post) {
+    const title = document.getElementById(`postTitle-${post.ID}`);
+    const content = document.getElementById(`postBody-${post.ID}`);
+    if (document.getElementById(`postEditForm-${post.ID}`)) return;
+
+    const form = document.createElement('div');
+    form.id = `postEditForm-${post.ID}`;
+    const titleInput = document.createElement('input');
+    titleInput.value = title.textContent;
+    titleInput.style.width = '100%';
+    titleInput.style.marginBottom = '0.5rem';
+    const bodyInput = document.createElement('textarea');
+    bodyInput.value = content.textContent;
+    bodyInput.style.width = '100%';
+
+    const close = () => {
+        form.remove();
+        title.style.display = '';
+        content.style.display = '';
+    };
+    const save = actionButton('Save', async () => {
+        const response = await fetch(`/api/posts/${post.ID}`, {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ title: titleInput.value.trim(), content: bodyInput.value.trim() }),
+        });
+        const result = await response.json();
+        if (!response.ok) {
+            alert(result.error || result.errors?.[0]?.message || 'Failed to edit post');
+            return;
+        }
+        close();
+        receivePostUpdate(result);
+    });
+
+    form.appendChild(titleInput);
+    form.appendChild(bodyInput);
+    form.appendChild(save);
+    form.appendChild(actionButton('Cancel', close));
+    title.style.display = 'none';
+    content.style.display = 'none';
+    content.after(form);
+}
+
+async function deletePost(postId) {
+    if (!confirm('Delete this post and its comments?')) return;
+
+    const response = await fetch(`/api/posts/${postId}`, { method: 'DELETE' });
+    if (!response.ok) {
+        const result = await response.json().catch(() => ({}));
+        alert(result.error || 'Failed to delete post');
+        return;
+    }
+    removePost(postId);
+    showUndo('Post deleted', async () => {
+        const restored = await fetch(`/api/posts/${postId}/restore`, { method: 'POST' });
+        const result = await restored.json();
+        if (!restored.ok) {
+            alert(result.error || 'Failed to restore post');
+            return;
+        }
+        receivePost(result);
+    });
+}
+
+function renderPost(postList, post, atTop = false) {
+    openPosts.add(post.ID);
+    const li = document.createElement('li');
+    li.id = `post-${post.ID}`;
+    li.style.border = '1px solid #ddd';
+    li.style.marginBottom = '1rem';
+    li.style.padding = '1rem';
+    li.style.borderRadius = '4px';
+    
+    const title = document.createElement('h3');
+    title.id = `postTitle-${post.ID}`;
+    title.textContent = post.Title || 'Untitled Post';
+    
+    const content = document.createElement('p');
+    content.id = `postBody-${post.ID}`;
+    content.textContent = post.Body || 'No content';
+
+    const date = new Date(post.CreatedAt);
+    
+    const formattedDate = date.getFullYear() + ' ' + 
+        String(date.getMonth() + 1).padStart(2, '0') + ' ' + 
+        String(date.getDate()).padStart(2, '0');
+    
+    const metadata = document.createElement('small');
+    metadata.textContent = `By: ${post.Username} | Date: ${formattedDate}`;
+    if (post.Categories && post.Categories.length > 0) {
+        metadata.textContent += ` | ${post.Categories.join(', ')}`;
+    }
+    const commentCount = document.createElement('span');
+    commentCount.id = `commentCount-${post.ID}`;
+    metadata.appendChild(commentCount);
+    const edited = document.createElement('span');
+    edited.id = `postEdited-${post.ID}`;
+    metadata.appendChild(edited);
+    updateEditedMarker(edited, `/api/posts/${post.ID}/revisions`, post.Revisions);
+    if (canModify(post.UserID)) {
+        metadata.appendChild(actionButton('Edit', () => editPost(post)));
+        metadata.appendChild(actionButton('Delete', () => deletePost(post.ID)));
+    }
+    
+    li.appendChild(title);
+    li.appendChild(content);
+    li.appendChild(metadata);
+
+    // Create comment section
+    const commentSection = document.createElement('div');
+    commentSection.style.marginTop = '1rem';
+    commentSection.style.padding = '1rem';
+    commentSection.style.borderTop = '1px solid #ddd';
+
+    const commentTitle = document.createElement('h4');
+    commentTitle.textContent = 'Comments';
+    commentSection.appendChild(commentTitle);
+
+    const commentList = document.createElement('ul');
+    commentList.id = `commentList-${post.ID}`;
+    commentList.style.listStyleType = 'none';
+    commentList.style.padding = '0';
+    commentSection.appendChild(commentList);
+
+    // Add comment input
+    const commentInputContainer = document.createElement('div');
+    commentInputContainer.style.marginTop = '1rem';
+
+    const commentInput = document.createElement('input');
+    commentInput.type = 'text';
+    commentInput.id = `newCommentBody-${post.ID}`;
+    commentInput.placeholder = 'Add a comment...';
+    commentInput.style.width = '100%';
+    commentInput.style.padding = '0.5rem';
+    commentInput.style.marginBottom = '0.5rem';
+
+    const submitCommentButton = document.createElement('button');
+    submitCommentButton.textContent = 'Comment';
+    submitCommentButton.id = `newCommentButton-${post.ID}`;
+    submitCommentButton.style.padding = '0.5rem 1rem';
+
+    commentInputContainer.appendChild(commentInput);
+    commentInputContainer.appendChild(submitCommentButton);
+    commentSection.appendChild(commentInputContainer);
+
+    li.appendChild(commentSection);
+    if (atTop) {
+        postList.prepend(li);
+    } else {
+        postList.appendChild(li);
+    }
+
+    setCommentCount(post.ID, post.CommentCount || 0);
+
+    // Populate comments and setup comment creation
+    populateCommentList(post.ID);
+    setupCommentCreation(post.ID);
+}
+
+// Builds the category filter above the posts and the category checkboxes
+// of the post creation form, and keeps their counts up to date
+async function populateCategories() {
+    let categories;
+    try {
+        categories = await fetchCategories();
+    } catch (error) {
+        return;
+    }
+
+    // Filter of the post list
+    let filter = document.getElementById('categoryFilter');
+    if (!filter) {
+        filter = document.createElement('select');
+        filter.id = 'categoryFilter';
+        filter.style.padding = '0.5rem';
+        filter.style.marginBottom = '1rem';
+        filter.addEventListener('change', () => {
+            selectedCategory = filter.value;
+            populatePostList();
+        });
+        const postsContainer = document.getElementById('posts-container');
+        postsContainer.parentNode.insertBefore(filter, postsContainer);
+
+        // Sort order, next to the filter
+        const sort = document.createElement('select');
+        sort.id = 'sortOrder';
+        sort.style.padding = '0.5rem';
+        sort.style.marginBottom = '1rem';
+        sort.style.marginLeft = '0.5rem';
+        [['newest', 'Newest'], ['comments', 'Most commented'], ['active', 'Recently active']].forEach(([value, text]) => {
+            const option = document.createElement('option');
+            option.value = value;
+            option.textContent = text;
+            sort.appendChild(option);
+        });
+        sort.value = selectedSort;
+        sort.addEventListener('change', () => {
+            selectedSort = sort.value;
+            populatePostList();
+        });
+        postsContainer.parentNode.insertBefore(sort, postsContainer);
+    }
+    filter.innerHTML = '';
+    const allOption = document.createElement('option');
+    allOption.value = '';
+    allOption.textContent = 'All categories';
+    filter.appendChild(allOption);
+    categories.forEach(category => {
+        const option = document.createElement('option');
+        option.value = category.name;
+        option.textContent = `${category.name} (${category.post_count})`;
+        filter.appendChild(option);
+    });
+    filter.value = selectedCategory;
+
+    // Checkboxes of the creation form, built once
+    if (document.getElementById('newPostCategories')) return;
+    const choices = document.createElement('div');
+    choices.id = 'newPostCategories';
+    choices.style.marginBottom = '0.5rem';
+    categories.forEach(category => {
+        const label = document.createElement('label');
+        label.style.marginRight = '1rem';
+        const checkbox = document.createElement('input');
+        checkbox.type = 'checkbox';
+        checkbox.value = category.name;
+        label.appendChild(checkbox);
+        label.appendChild(document.createTextNode(' ' + category.name));
+        choices.appendChild(label);
+    });
+    const submitButton = document.getElementById('submitPostButton');
+    submitButton.parentNode.insertBefore(choices, submitButton);
+}
+
 export function setupPostCreation() {
     const titleInput = document.getElementById('newPostTitle');
     const postInput = document.getElementById('newPostInput');
     const submitButton = document.getElementById('submitPostButton');
+
+    populateCategories();
     
     submitButton.addEventListener('click', async () => {
         const postTitle = titleInput.value.trim();
         const postContent = postInput.value.trim();
+        const checkboxes = document.querySelectorAll('#newPostCategories input:checked');
+        const categories = Array.from(checkboxes).map(checkbox => checkbox.value);
     
         console.log("Post Content: ", postContent);
         
-        if (postTitle && postContent) {
+        if (postTitle && postContent && categories.length > 0) {
             try {
                 const response = await fetch('/api/postCreation', {
                     method: 'POST',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({
                         title: postTitle,
-                        content: postContent
+                        content: postContent,
+                        categories: categories
                     }),
                 });
                 
                 const newPost = await response.json();
+                if (!response.ok) {
+                    console.error('Post refused:', newPost);
+                    return;
+                }
                 // console.log('Post created:', newPost);
                 
                 // Clear the input fields after successful submission
                 titleInput.value = '';
                 postInput.value = '';
+                checkboxes.forEach(checkbox => { checkbox.checked = false; });
                 
                 // Refresh the post list to show the new post
                 populatePostList();
+                populateCategories();
             } catch (error) {
                 console.error('Error creating post:', error);
             }
         } else {
-            console.warn('Post title and content are required');
+            console.warn('Post title, content and at least one category are required');
         }
     });
 }
\ No newline at end of file
@@ -1,4 +1,5 @@
 import { getSocket } from "./websockets.js";
+import { showUndo } from "./editing.js";
 
 // Global variables
 let chatWindow = null;
@@ -7,7 +8,9 @@ let currentTab = null;
 let currentUsername = null;
 let unreadMessages = {}; // Pour suivre les messages non lus par utilisateur
 let messageHistories = {}; // Store message histories by username
-let messagesPerPage = 10; // Number of messages to load at once
+let historyState = {}; // Pagination cursor of each open conversation
+let lastReceivedIds = {}; // Latest message id received from each user
+let lastReadIds = {}; // Latest message id we told the server we've read
 
 // Initialize private messaging functionality
 export function initializePrivateMessaging() {
@@ -57,8 +60,36 @@ function setupUserListClickHandlers() {
   });
 }
 
+// A user changed their nickname: follow them in the open chat tabs, and
+// take the new name if it was us
+export function renameChatUser(oldName, newName) {
+  if (currentUsername === oldName) {
+    currentUsername = newName;
+    const usernameElement = document.getElementById('username');
+    if (usernameElement) {
+      usernameElement.textContent = newName;
+    }
+  }
+
+  chatTabs.filter(tab => tab.username === oldName).forEach(tab => {
+    tab.username = newName;
+    const tabName = document.querySelector(`#${tab.id} span`);
+    if (tabName) {
+      tabName.textContent = newName;
+    }
+  });
+
+  // Per user state is keyed by nickname
+  [unreadMessages, messageHistories, historyState, lastReceivedIds, lastReadIds].forEach(state => {
+    if (oldName in state) {
+      state[newName] = state[oldName];
+      delete state[oldName];
+    }
+  });
+}
+
 // Function to handle incoming private messages
-export function receivePrivateMessage(sender, messageText) {
+export function receivePrivateMessage(sender, messageText, messageId) {
   // Gérer les messages même si la chat window n'est pas créée
   if (!chatWindow) {
     createChatWindow();
@@ -87,11 +118,17 @@ export function receivePrivateMessage(sender, messageText) {
   }
   
   // Afficher le message reçu dans tous les cas
-  displayReceivedMessage(sender, messageText);
+  displayReceivedMessage(sender, messageText, messageId);
   
+  if (messageId) {
+    lastReceivedIds[sender] = Math.max(lastReceivedIds[sender] || 0, messageId);
+  }
+
   // Ajouter la notification si le chat n'est pas visible
   if (!isVisible) {
     addMessageNotification(sender);
+  } else {
+    markConversationRead(sender);
   }
   
   // Déplacer l'expéditeur en haut de la liste
@@ -99,15 +136,7 @@ export function receivePrivateMessage(sender, messageText) {
 }
 
 // Function to handle chat history
-export function receiveChatHistory(sender, messages) {
-  // Store the full message history
-  if (!messageHistories[sender]) {
-    messageHistories[sender] = [];
-  }
-  
-  // Add messages to history (messages should be in chronological order)
-  messageHistories[sender] = messages;
-  
+export function receiveChatHistory(sender, messages, hasMore, before) {
   // Find the tab for this user
   const tabData = chatTabs.find(tab => tab.username === sender);
   if (!tabData) return;
@@ -115,13 +144,27 @@ export function receiveChatHistory(sender, messages) {
   // Get the content element
   const contentElement = document.getElementById(tabData.contentId);
   if (!contentElement) return;
+
+  // An older page was requested while scrolling up
+  if (before) {
+    prependOlderMessages(sender, contentElement, messages, hasMore);
+    return;
+  }
+  
+  // Store this page as the start of the message history
+  messageHistories[sender] = messages;
+  historyState[sender] = {
+    oldestId: messages.length > 0 ? messages[0].id : 0,
+    hasMore: hasMore,
+    isLoadingMore: false,
+  };
   
   // Clear any existing content
   contentElement.innerHTML = '';
   
   // Create loading indicator at the top
   const loadingIndicator = document.createElement('div');
-  loadingIndicator.id = `loading-${tabData.id}`;
+  loadingIndicator.className = 'loading-indicator';
   loadingIndicator.textContent = 'Loading older messages...';
   loadingIndicator.style.textAlign = 'center';
   loadingIndicator.style.color = '#888';
@@ -137,83 +180,134 @@ export function receiveChatHistory(sender, messages) {
   messageContainer.style.minHeight = '100%';
   contentElement.appendChild(messageContainer);
   
-  // Track displayed messages and current position
-  let displayedCount = 0;
-  let isLoadingMore = false;
-  
-  // Add scroll event for infinite scrolling
-  contentElement.addEventListener('scroll', function() {
-    // If we're near the top and not currently loading
-    if (contentElement.scrollTop < 50 && !isLoadingMore) {
-      // Check if there are more messages to load
-      if (displayedCount < messageHistories[sender].length) {
-        isLoadingMore = true;
-        loadingIndicator.style.display = 'block';
-        
-        // Get current scroll height to maintain position
-        const scrollHeight = contentElement.scrollHeight;
-        
-        setTimeout(() => {
-          // Calculate how many messages to load
-          const startIndex = Math.max(0, messageHistories[sender].length - displayedCount - messagesPerPage);
-          const endIndex = messageHistories[sender].length - displayedCount;
-          
-          // Get the batch of older messages
-          const nextBatch = messageHistories[sender].slice(startIndex, endIndex);
-          
-          // Add older messages at the beginning of the container
-          nextBatch.forEach(msg => {
-            const messageElement = createMessageElement(
-              msg.sender === currentUsername ? 'sent' : 'received',
-              msg.message,
-              msg.sender
-            );
-            
-            // Insert at the beginning
-            messageContainer.insertBefore(messageElement, messageContainer.firstChild);
-            displayedCount++;
-          });
-          
-          // Calculate how much the scroll height has changed
-          const newScrollHeight = contentElement.scrollHeight;
-          const scrollDiff = newScrollHeight - scrollHeight;
-          
-          // Adjust scroll position to keep the view stable
-          contentElement.scrollTop = scrollDiff;
-          
-          // Hide loading indicator and reset flag
-          loadingIndicator.style.display = 'none';
-          isLoadingMore = false;
-        }, 300);
+  // Ask the server for the previous page when scrolling near the top
+  if (!contentElement.dataset.scrollHandler) {
+    contentElement.dataset.scrollHandler = 'true';
+    contentElement.addEventListener('scroll', function() {
+      const state = historyState[sender];
+      if (contentElement.scrollTop < 50 && state && state.hasMore && !state.isLoadingMore) {
+        state.isLoadingMore = true;
+        contentElement.querySelector('.loading-indicator').style.display = 'block';
+        getSocket().getChatHistory(sender, state.oldestId);
       }
-    }
-  });
-  
-  // Load initial batch of messages (most recent)
-  const initialBatchSize = Math.min(messagesPerPage, messages.length);
-  const initialMessages = messages.slice(messages.length - initialBatchSize);
+    });
+  }
   
-  // Display the initial messages
-  initialMessages.forEach(msg => {
-    console.log("test");
-    
+  // Display the most recent messages
+  messages.forEach(msg => {
     const messageElement = createMessageElement(
       msg.sender === currentUsername ? 'sent' : 'received',
       msg.message,
-      msg.sender
+      msg.sender,
+      msg.id,
+      msg.deleted
     );
     messageContainer.appendChild(messageElement);
-    displayedCount++;
   });
-  
+
+  // Show whether our last message has been seen
+  const lastMessage = messages[messages.length - 1];
+  if (lastMessage && lastMessage.sender === currentUsername && lastMessage.read) {
+    showReadReceipt(sender);
+  }
+
+  // Everything from this user is on screen now
+  messages.forEach(msg => {
+    if (msg.sender === sender) {
+      lastReceivedIds[sender] = Math.max(lastReceivedIds[sender] || 0, msg.id);
+    }
+  });
+  if (currentTab === tabData.id && chatWindow && chatWindow.style.display !== 'none') {
+    markConversationRead(sender);
+  }
+
   // Scroll to the bottom to show most recent messages
   contentElement.scrollTop = contentElement.scrollHeight;
 }
 
+// Insert a page of older messages above the ones already displayed
+function prependOlderMessages(sender, contentElement, messages, hasMore) {
+  const state = historyState[sender];
+  const messageContainer = contentElement.querySelector('.message-container');
+  if (!state || !messageContainer) return;
+
+  // Get current scroll height to maintain position
+  const scrollHeight = contentElement.scrollHeight;
+
+  // Messages arrive oldest first, insert them newest first at the top
+  for (let i = messages.length - 1; i >= 0; i--) {
+    const msg = messages[i];
+    const messageElement = createMessageElement(
+      msg.sender === currentUsername ? 'sent' : 'received',
+      msg.message,
+      msg.sender,
+      msg.id,
+      msg.deleted
+    );
+    messageContainer.insertBefore(messageElement, messageContainer.firstChild);
+  }
+  messageHistories[sender] = messages.concat(messageHistories[sender] || []);
+
+  if (messages.length > 0) {
+    state.oldestId = messages[0].id;
+  }
+  state.hasMore = hasMore;
+  state.isLoadingMore = false;
+
+  // Keep the view stable where the user was reading
+  contentElement.scrollTop = contentElement.scrollHeight - scrollHeight;
+  contentElement.querySelector('.loading-indicator').style.display = 'none';
+}
+
+// Tell the server we've seen everything received from this user so far
+function markConversationRead(username) {
+  const lastId = lastReceivedIds[username];
+  if (!lastId || lastId <= (lastReadIds[username] || 0)) return;
+
+  const socket = getSocket();
+  if (socket && socket.markRead) {
+    socket.markRead(username, lastId);
+    lastReadIds[username] = lastId;
+  }
+}
+
+// Show a "Seen" mark under our last message once reader has read it
+export function showReadReceipt(reader) {
+  const tabData = chatTabs.find(tab => tab.username === reader);
+  if (!tabData) return;
+
+  const contentElement = document.getElementById(tabData.contentId);
+  const messageContainer = contentElement && contentElement.querySelector('.message-container');
+  if (!messageContainer) return;
+
+  clearReadReceipt(messageContainer);
+
+  const receipt = document.createElement('div');
+  receipt.className = 'read-receipt';
+  receipt.textContent = 'Seen';
+  receipt.style.alignSelf = 'flex-end';
+  receipt.style.fontSize = '0.75rem';
+  receipt.style.color = '#888';
+  messageContainer.appendChild(receipt);
+}
+
+// Remove the "Seen" mark, e.g. when we send a new message
+function clearReadReceipt(messageContainer) {
+  const receipt = messageContainer.querySelector('.read-receipt');
+  if (receipt) {
+    receipt.remove();
+  }
+}
+
 // Helper function to create message elements
-function createMessageElement(type, messageText, sender) {
+// Messages with an id can be found again when they are deleted or restored,
+// and our own can be deleted
+function createMessageElement(type, messageText, sender, messageId, deleted) {
   // Create message container
   const messageContainer = document.createElement('div');
+  if (messageId) {
+    messageContainer.dataset.messageId = messageId;
+  }
   messageContainer.style.display = 'flex';
   messageContainer.style.flexDirection = 'column';
   messageContainer.style.margin = '5px 0';
@@ -241,15 +335,57 @@ function createMessageElement(type, messageText, sender) {
   
   // Add the message text
   messageElement.textContent = messageText;
+  if (deleted) {
+    messageElement.style.fontStyle = 'italic';
+    messageElement.style.opacity = '0.6';
+  }
   
   // Add the message to the container
   messageContainer.appendChild(messageElement);
+
+  if (type === 'sent' && messageId && !deleted) {
+    const deleteLink = document.createElement('a');
+    deleteLink.href = '#';
+    deleteLink.textContent = 'Delete';
+    deleteLink.style.alignSelf = 'flex-end';
+    deleteLink.style.fontSize = '0.75em';
+    deleteLink.style.color = '#888';
+    deleteLink.addEventListener('click', (event) => {
+      event.preventDefault();
+      deleteMessage(messageId);
+    });
+    messageContainer.appendChild(deleteLink);
+  }
   
   return messageContainer;
 }
 
+// Delete one of our messages, the server tells both sides to show the
+// placeholder instead
+async function deleteMessage(messageId) {
+  const response = await fetch(`/api/messages/${messageId}`, { method: 'DELETE' });
+  if (!response.ok) {
+    const result = await response.json().catch(() => ({}));
+    alert(result.error || 'Failed to delete message');
+    return;
+  }
+  showUndo('Message deleted', async () => {
+    const restored = await fetch(`/api/messages/${messageId}/restore`, { method: 'POST' });
+    if (!restored.ok) alert('Failed to restore message');
+  });
+}
+
+// Show a message that was deleted (placeholder text) or restored, wherever
+// it is displayed
+export function receiveMessageChange(messageId, messageText, sender, deleted) {
+  document.querySelectorAll(`[data-message-id="${messageId}"]`).forEach(element => {
+    const type = sender === currentUsername ? 'sent' : 'received';
+    element.replaceWith(createMessageElement(type, messageText, sender, messageId, deleted));
+  });
+}
+
 // Function to display a received message in the appropriate chat tab
-function displayReceivedMessage(sender, messageText) {
+function displayReceivedMessage(sender, messageText, messageId) {
   // Create chat window if it doesn't exist
   if (!chatWindow) {
     createChatWindow();
@@ -286,7 +422,7 @@ function displayReceivedMessage(sender, messageText) {
   }
   
   // Add the new message to the container
-  const messageElement = createMessageElement('received', messageText, sender);
+  const messageElement = createMessageElement('received', messageText, sender, messageId);
   messageContainer.appendChild(messageElement);
   
   // Scroll to the bottom
@@ -708,6 +844,7 @@ function switchToTab(tabId) {
   if (tabData) {
     // Réinitialiser le compteur de messages non lus pour cet utilisateur
     unreadMessages[tabData.username] = 0;
+    markConversationRead(tabData.username);
     
     // Supprimer également le point de notification dans la liste des utilisateurs
     const userListItems = document.querySelectorAll('#userList li');
@@ -800,6 +937,9 @@ function sendMessage() {
     contentElement.appendChild(messageContainer);
   }
 
+  // Our new message hasn't been seen yet
+  clearReadReceipt(messageContainer);
+
   // Create sent message element
   const messageElement = createMessageElement('sent', messageText, currentUsername);
 
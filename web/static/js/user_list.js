@@ -2,27 +2,61 @@
 // import {user}
 import { initializePrivateMessaging } from "./private_message.js";
 
+let onlineUsers = []; // Users currently connected, from the last user_list frame
+let conversations = []; // Conversation list entries, most recent first
+
+// Load the conversation list of the current user from the server
+export async function loadConversations() {
+  try {
+    const response = await fetch('/api/conversations', { credentials: 'include' });
+    if (!response.ok) return;
+    const data = await response.json();
+    conversations = data.conversations || [];
+    renderUserList();
+  } catch (error) {
+    console.error('Error fetching conversations:', error);
+  }
+}
+
+// Move an updated conversation to the top of the list
+export function updateConversation(conversation) {
+  conversations = conversations.filter(c => c.user !== conversation.user);
+  conversations.unshift(conversation);
+  renderUserList();
+}
+
 // Function to populate the user list on the left of the page
 export async function populateUserList(userlist) {
+  onlineUsers = userlist || [];
+  conversations.forEach(c => c.online = onlineUsers.includes(c.user));
+  renderUserList();
+}
+
+// Render the conversations followed by the connected users without one
+function renderUserList() {
   const userList = document.getElementById('userList');
+  if (!userList) return;
   userList.innerHTML = ''; // Clear existing users
   
   try {
+    // Create a section for conversations, most recent message first
+    if (conversations.length > 0) {
+      userList.appendChild(createSectionHeader('Conversations'));
+      conversations.forEach(conversation => {
+        userList.appendChild(createConversationItem(conversation));
+      });
+    }
+
     // Create a section for connected users
-    if (userlist) {
-      const connectedHeader = document.createElement('li');
-      connectedHeader.textContent = 'Connected Users';
-      connectedHeader.style.fontWeight = 'bold';
-      connectedHeader.style.padding = '0.5rem';
-      connectedHeader.style.backgroundColor = '#f0f0f0';
-      userList.appendChild(connectedHeader);
-      
-      userlist.forEach(user => {
+    const others = onlineUsers.filter(user => !conversations.some(c => c.user === user));
+    if (others.length > 0) {
+      userList.appendChild(createSectionHeader('Connected Users'));
+      others.forEach(user => {
         // console.log('Processing connected user:', user);
         const li = createUserListItem(user, true);
         userList.appendChild(li);
       });
-    } else {
+    } else if (conversations.length === 0) {
       const li = document.createElement('li');
       li.textContent = 'No connected users';
       userList.appendChild(li);
@@ -38,6 +72,50 @@ export async function populateUserList(userlist) {
   }
 }
 
+// Create the bold header of a section of the list
+function createSectionHeader(title) {
+  const header = document.createElement('li');
+  header.textContent = title;
+  header.style.fontWeight = 'bold';
+  header.style.padding = '0.5rem';
+  header.style.backgroundColor = '#f0f0f0';
+  return header;
+}
+
+// Create the item of a conversation, with a preview of its last message
+function createConversationItem(conversation) {
+  const li = createUserListItem(conversation.user, conversation.online);
+  li.style.flexWrap = 'wrap';
+
+  if (conversation.unread > 0) {
+    const unread = document.createElement('div');
+    unread.className = 'notification-dot';
+    unread.textContent = conversation.unread;
+    unread.style.marginLeft = 'auto';
+    unread.style.padding = '0 6px';
+    unread.style.borderRadius = '10px';
+    unread.style.backgroundColor = 'red';
+    unread.style.color = 'white';
+    unread.style.fontSize = '0.75rem';
+    li.appendChild(unread);
+  }
+
+  const preview = document.createElement('div');
+  preview.className = 'conversation-preview';
+  preview.textContent = (conversation.last_sender === conversation.user ? '' : 'You: ') + conversation.last_message;
+  preview.title = conversation.timestamp;
+  preview.style.width = '100%';
+  preview.style.paddingLeft = '22px';
+  preview.style.color = '#888';
+  preview.style.fontSize = '0.8rem';
+  preview.style.overflow = 'hidden';
+  preview.style.whiteSpace = 'nowrap';
+  preview.style.textOverflow = 'ellipsis';
+  li.appendChild(preview);
+
+  return li;
+}
+
 // Function to create an element for a user in the list
 export function createUserListItem(user, isConnected) {
   const li = document.createElement('li');
@@ -56,7 +134,7 @@ export function createUserListItem(user, isConnected) {
   statusCircle.style.borderRadius = '50%';
   statusCircle.style.marginRight = '10px';
   
-  statusCircle.style.backgroundColor = '#2ecc71';
+  statusCircle.style.backgroundColor = isConnected ? '#2ecc71' : '#bbb';
   
   // Username text
   const userText = document.createElement('span');
@@ -1,6 +1,8 @@
 import { getUsername } from "./getUser.js";
-import { populateUserList } from "./user_list.js";
-import { receivePrivateMessage, receiveChatHistory, showTypingIndicator } from "./private_message.js";
+import { populateUserList, loadConversations, updateConversation } from "./user_list.js";
+import { receivePrivateMessage, receiveChatHistory, showTypingIndicator, showReadReceipt, renameChatUser, receiveMessageChange } from "./private_message.js";
+import { receivePost, receivePostUpdate, removePost, followOpenPosts } from "./posts.js";
+import { receiveComment, receiveCommentUpdate, removeComment, receiveCommentRestore } from "./comment.js";
 
 let socket = null;
 
@@ -26,6 +28,9 @@ export async function setupWebSockets() {
     // Method that triggers when the connection is established
     socket.onopen = function () {
         console.log("WebSocket connection established");
+        loadConversations();
+        // A new connection starts without subscriptions
+        followOpenPosts();
     };
 
     // Method that triggers when an error occurs
@@ -34,9 +39,23 @@ export async function setupWebSockets() {
     };
 
     // Method that triggers when connection is closed (attempt to reconnect)
-    socket.onclose = function () {
+    socket.onclose = function (event) {
+        // 1008 (policy violation): our session is gone, back to the login page
+        if (event.code === 1008) {
+            console.log("WebSocket closed by the server:", event.reason);
+            sessionEnded(event.reason);
+            return;
+        }
         console.log("WebSocket connection closed. Attempting to reconnect...");
-        setTimeout(() => setupWebSockets(username), 3000);
+        setTimeout(async () => {
+            // Don't retry forever with a session that has ended meanwhile
+            const session = await checkSession();
+            if (session && !session.loggedIn) {
+                sessionEnded(session.reason);
+                return;
+            }
+            setupWebSockets(username);
+        }, 3000);
     };
 
     // Method that triggers when a message is received from the server
@@ -48,8 +67,14 @@ export async function setupWebSockets() {
             switch (data.type) {
                 // When a private message is received
                 case 'private_message':
-                    receivePrivateMessage(data.sender, data.message);
+                    receivePrivateMessage(data.sender, data.message, data.id);
                     console.log(username, 'received private message:', data);
+                    socket.acknowledgeMessages([data.id]);
+                    break;
+                // Messages received while we were offline
+                case 'pending_messages':
+                    data.messages.forEach(msg => receivePrivateMessage(msg.sender, msg.message, msg.id));
+                    socket.acknowledgeMessages(data.messages.map(msg => msg.id));
                     break;
                 // When someone connects or disconnects    
                 case 'user_list':
@@ -59,14 +84,66 @@ export async function setupWebSockets() {
                 // When someone opens a chat
                 case 'chat_history':
                     console.log('Chat history:', data);
-                    receiveChatHistory(data.user2name, data.messages);
+                    receiveChatHistory(data.user2name, data.messages, data.has_more, data.before);
+                    break;
+                // When a message changed one of our conversations
+                case 'conversation_update':
+                    updateConversation(data);
+                    break;
+                // When the other user has read our messages
+                case 'read_receipt':
+                    showReadReceipt(data.sender);
                     break;
                 case 'typing':
                     showTypingIndicator(data.sender)
                     break;
+                // When a message of one of our conversations was deleted or restored
+                case 'message_deleted':
+                case 'message_restored':
+                    receiveMessageChange(data.id, data.message, data.sender, data.type === 'message_deleted');
+                    break;
+                // When someone changed their nickname, maybe us
+                case 'user_renamed':
+                    if (data.sender === username) {
+                        username = data.message;
+                    }
+                    renameChatUser(data.sender, data.message);
+                    loadConversations();
+                    break;
+                // When someone published a post, or commented one we have open
+                case 'post_created':
+                    receivePost(data.post);
+                    break;
+                case 'comment_created':
+                    receiveComment(data.comment);
+                    break;
+                // When a post or one of the comments we have open changed
+                case 'post_updated':
+                    receivePostUpdate(data.post);
+                    break;
+                case 'post_deleted':
+                    removePost(data.post.ID);
+                    break;
+                case 'comment_updated':
+                    receiveCommentUpdate(data.comment);
+                    break;
+                case 'comment_deleted':
+                    removeComment(data.comment);
+                    break;
+                // When a deleted post or comment was brought back
+                case 'post_restored':
+                    receivePost(data.post);
+                    break;
+                case 'comment_restored':
+                    receiveCommentRestore(data.comment);
+                    break;
                 case 'system_notification':
                     console.log('System notification:', data.message);
                     break;
+                // When the server ignored one of our frames
+                case 'error':
+                    console.error('Server rejected message:', data.message);
+                    break;
                 default:
                     console.log('Received data:', data);
             }
@@ -92,8 +169,33 @@ export async function setupWebSockets() {
         }
     };
 
+    // Function to tell the server which messages reached us
+    socket.acknowledgeMessages = function (messageIds) {
+        if (messageIds.length === 0) {
+            return;
+        }
+        if (socket.readyState === WebSocket.OPEN) {
+            socket.send(JSON.stringify({
+                type: "message_ack",
+                message_ids: messageIds,
+            }));
+        }
+    };
+
+    // Function to tell the server we read a conversation up to a message
+    socket.markRead = function (partner, messageId) {
+        if (socket.readyState === WebSocket.OPEN) {
+            socket.send(JSON.stringify({
+                type: "mark_read",
+                receiver: partner,
+                id: messageId,
+            }));
+        }
+    };
+
     // Function to get the history of messages between 2 users
-    socket.getChatHistory = function (receiver) {
+    // `before` is the oldest message id already loaded, omit it for the latest page
+    socket.getChatHistory = function (receiver, before) {
         console.log(username, "Requests chat history with", receiver);
         
         // Checking the state of the websocket connection
@@ -102,6 +204,7 @@ export async function setupWebSockets() {
                 type: "chat_history_request",
                 sender: username,
                 receiver: receiver,
+                before: before || 0,
             };
             socket.send(JSON.stringify(chatHistoryRequest));
         } else {
@@ -126,6 +229,19 @@ export async function setupWebSockets() {
         }
     }
 
+    // Functions to start and stop receiving the new comments of posts
+    socket.subscribePosts = function (postIds) {
+        if (postIds.length > 0 && socket.readyState === WebSocket.OPEN) {
+            socket.send(JSON.stringify({ type: "subscribe", post_ids: postIds }));
+        }
+    };
+
+    socket.unsubscribePosts = function (postIds) {
+        if (postIds.length > 0 && socket.readyState === WebSocket.OPEN) {
+            socket.send(JSON.stringify({ type: "unsubscribe", post_ids: postIds }));
+        }
+    };
+
     return socket;
 }
 
@@ -133,3 +249,21 @@ export async function setupWebSockets() {
 export function getSocket() {
     return socket;
 }
+
+// Ask the server whether our session is still valid, null if it can't be reached
+async function checkSession() {
+    try {
+        const response = await fetch('/api/check-session', { credentials: 'include' });
+        return await response.json();
+    } catch (error) {
+        return null;
+    }
+}
+
+// Go back to the login page, saying so when the session merely expired
+function sessionEnded(reason) {
+    if (reason === 'session_expired') {
+        alert('Your session has expired, please log in again.');
+    }
+    window.location.reload();
+}
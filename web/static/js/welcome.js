@@ -2,10 +2,20 @@
 import { login } from "./auth/login.js";
 import { createMainPage } from "./main.js";
 import { replaceWithRegistrationForm } from "./auth/register.js";
+import { replaceWithResetRequestForm, replaceWithResetConfirmForm } from "./auth/passwordReset.js";
 import { checkSession } from "./auth/checkSession.js";
+import { verifyEmailFromLink } from "./auth/verifyEmail.js";
 import { setupWebSockets } from "./websockets.js";
 
 document.addEventListener('DOMContentLoaded', async function() {
+  // Coming from a verification email: confirm the address first, so the
+  // page shows the account as verified
+  const verifyToken = new URLSearchParams(window.location.search).get('verify_token');
+  if (verifyToken) {
+    window.history.replaceState(null, '', window.location.pathname);
+    await verifyEmailFromLink(verifyToken);
+  }
+
   // Check if user is already logged in
   const isLoggedIn = await checkSession();
   
@@ -16,6 +26,14 @@ document.addEventListener('DOMContentLoaded', async function() {
   } else {
     // User is not logged in, show welcome/login page
     createWelcomePage();
+
+    // Coming from a password reset email: ask for the new password, and
+    // drop the token from the address bar
+    const resetToken = new URLSearchParams(window.location.search).get('reset_token');
+    if (resetToken) {
+      window.history.replaceState(null, '', window.location.pathname);
+      replaceWithResetConfirmForm(resetToken);
+    }
   }
 });
 
@@ -211,6 +229,16 @@ export function createWelcomePage() {
   buttonGroup.appendChild(loginButton);
   buttonGroup.appendChild(registerButton);
 
+  // Forgotten password link
+  const forgotLink = document.createElement('a');
+  forgotLink.href = '#';
+  forgotLink.id = 'forgotPasswordLink';
+  forgotLink.textContent = 'Forgot your password?';
+  forgotLink.style.display = 'block';
+  forgotLink.style.marginTop = '1rem';
+  forgotLink.style.textAlign = 'center';
+  forgotLink.style.color = '#3498db';
+
   let message = document.createElement('div')
   message.setAttribute('class', "message")
   message.setAttribute('style', "display: none;")
@@ -220,6 +248,7 @@ export function createWelcomePage() {
   loginForm.appendChild(usernameGroup);
   loginForm.appendChild(passwordGroup);
   loginForm.appendChild(buttonGroup);
+  loginForm.appendChild(forgotLink);
   
   // Add form to login container
   loginContainer.appendChild(loginForm);
@@ -319,6 +348,11 @@ export function createWelcomePage() {
   registerButton.addEventListener('click', function() {
     replaceWithRegistrationForm()
   });
+
+  forgotLink.addEventListener('click', function(event) {
+    event.preventDefault();
+    replaceWithResetRequestForm();
+  });
   
   // Add all elements to body
   document.body.appendChild(header);